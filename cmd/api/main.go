@@ -11,6 +11,7 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/sawalreverr/cv-reviewer/config"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
 	"github.com/sawalreverr/cv-reviewer/internal/handler"
 	"github.com/sawalreverr/cv-reviewer/internal/repository"
 	"github.com/sawalreverr/cv-reviewer/internal/service"
@@ -23,6 +24,9 @@ func main() {
     if err != nil {
         log.Fatalf("failed to load config: %v", err)
     }
+	if _, err := domain.ParseMetadataFilter(cfg.Retrieval.MetadataFilter); err != nil {
+		log.Fatalf("invalid RETRIEVAL_METADATA_FILTER: %v", err)
+	}
 
 	// init db
 	db, err := config.NewDatabase(&cfg.Database)
@@ -31,7 +35,7 @@ func main() {
 	}
 	
 	// run migrations
-	if err := config.RunMigration(db); err != nil {
+	if err := config.RunMigration(db, int(*cfg.Gemini.Dimension)); err != nil {
 		log.Fatalf("failed to run migrations: %v", err)
 	}
 
@@ -40,38 +44,82 @@ func main() {
 	evaluationJobRepo := repository.NewEvaluationJobRepository(db)
 	evaluationResultRepo := repository.NewEvaluationResultRepository(db)
 	vectorRepo := repository.NewVectorRepository(db)
+	llmCallRepo := repository.NewLLMCallRepository(db)
+	positionRepo := repository.NewPositionRepository(db)
+	kbVersionRepo := repository.NewKBVersionRepository(db)
+
+	// llm cache is optional
+	var llmCacheRepo domain.LLMCacheRepository
+	if cfg.LLMCache.Enabled {
+		llmCacheRepo = repository.NewLLMCacheRepository(db)
+	}
 
 	// init services
 	pdfService := service.NewPDFService()
 	chunkingService := service.NewChunkingService()
+	injectionDetector := service.NewInjectionDetector()
 
-	embeddingService, err := service.NewEmbeddingService(&cfg.Gemini)
+	// one gemini budget for every worker, shared across replicas when configured
+	rateLimiter := service.NewRateLimiter(&cfg.RateLimit, repository.NewRateLimitRepository(db))
+
+	embeddingService, err := service.NewEmbeddingService(&cfg.Gemini, rateLimiter)
 	if err != nil {
 		log.Fatalf("failed to create embedding service: %v", err)
 	}
 	
-	llmService, err := service.NewLLMService(&cfg.Gemini)
+	// load and validate prompt templates
+	prompts, err := service.LoadPromptTemplates(&cfg.Prompt)
+	if err != nil {
+		log.Fatalf("failed to load prompt templates: %v", err)
+	}
+	log.Printf("loaded prompt templates version %s", prompts.Version())
+
+	llmService, err := service.NewLLMService(&cfg.Gemini, &cfg.Evaluation, &cfg.Pricing, &cfg.LLMCache, llmCacheRepo, &cfg.Audit, prompts, rateLimiter)
 	if err != nil {
 		log.Fatalf("failed to create llm service: %v", err)
 	}
 
 	// init usecases
 	documentUsecase := usecase.NewDocumentUsecase(documentRepo, &cfg.Storage)
-	vectorUsecase := usecase.NewVectorUsecase(vectorRepo, pdfService, chunkingService, embeddingService)
-	evaluationUsecase := usecase.NewEvaluationUsecase(evaluationJobRepo, evaluationResultRepo, documentRepo, vectorUsecase, pdfService, llmService, cfg.Queue.JobTimeout)
+	vectorUsecase := usecase.NewVectorUsecase(vectorRepo, kbVersionRepo, pdfService, chunkingService, embeddingService, &cfg.Retrieval)
+	// refuse to start when the vector column and embedding model disagree on dimension
+	checkCtx, cancelCheck := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := vectorUsecase.CheckDimension(checkCtx, int(*cfg.Gemini.Dimension)); err != nil {
+		log.Fatalf("embedding dimension check failed: %v", err)
+	}
+	cancelCheck()
+
+	usageUsecase := usecase.NewUsageUsecase(evaluationJobRepo, llmCallRepo)
+	auditUsecase := usecase.NewAuditUsecase(evaluationJobRepo, llmCallRepo)
+	positionUsecase := usecase.NewPositionUsecase(positionRepo, vectorRepo)
+	knowledgeBaseUsecase := usecase.NewKnowledgeBaseUsecase(positionRepo, documentUsecase, vectorUsecase, kbVersionRepo, cfg.Queue.JobTimeout)
+	evaluationUsecase := usecase.NewEvaluationUsecase(evaluationJobRepo, evaluationResultRepo, documentRepo, positionRepo, llmCallRepo, vectorUsecase, pdfService, llmService, injectionDetector, &cfg.Evaluation, cfg.Queue.JobTimeout)
 
 	// init job queue
-	jobQueue := service.NewJobQueue(&cfg.Queue, evaluationUsecase)
+	jobQueue := service.NewJobQueue(&cfg.Queue, map[service.JobKind]service.JobProcessor{
+		service.JobEvaluation: evaluationUsecase,
+		service.JobIngest: knowledgeBaseUsecase,
+	})
 
 	// start workers
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	jobQueue.Start(ctx)
 
+	// hourly cleanup of llm cache and audit trail
+	go runMaintenance(ctx, llmCacheRepo, llmCallRepo, cfg.Audit.RetentionDays)
+
+	// ingest jobs only live in the in-memory queue, retry the ones lost by a restart
+	go runIngestRecovery(ctx, knowledgeBaseUsecase, jobQueue, 2*time.Duration(cfg.Queue.JobTimeout)*time.Second)
+
 	// init handlers
-	healthHandler := handler.NewHealthHandler()
+	healthHandler := handler.NewHealthHandler(llmService)
 	documentHandler := handler.NewDocumentHandler(documentUsecase)
 	evaluationHandler := handler.NewEvaluationHandler(evaluationUsecase, jobQueue)
+	usageHandler := handler.NewUsageHandler(usageUsecase)
+	auditHandler := handler.NewAuditHandler(auditUsecase)
+	positionHandler := handler.NewPositionHandler(positionUsecase)
+	knowledgeBaseHandler := handler.NewKnowledgeBaseHandler(knowledgeBaseUsecase, jobQueue)
 
 	// init echo
 	e := echo.New()
@@ -85,6 +133,22 @@ func main() {
 	e.POST("/upload", documentHandler.Upload)
 	e.POST("/evaluate", evaluationHandler.Evaluate)
 	e.GET("/result/:id", evaluationHandler.GetResult)
+	e.GET("/results", evaluationHandler.ListResults)
+	e.GET("/positions", positionHandler.List)
+	e.GET("/positions/:id", positionHandler.Get)
+
+	// admin routes
+	admin := e.Group("/admin", handler.AdminAuth(cfg.Audit.AdminAPIKey))
+	admin.GET("/jobs/:id/llm-calls", auditHandler.GetJobLLMCalls)
+	admin.GET("/usage/jobs/:id", usageHandler.GetJobUsage)
+	admin.GET("/usage/daily", usageHandler.GetDailyUsage)
+	admin.POST("/positions", positionHandler.Create)
+	admin.POST("/positions/:id/kb", knowledgeBaseHandler.Upload)
+	admin.GET("/positions/:id/kb", knowledgeBaseHandler.Get)
+	admin.GET("/kb/versions/:id", knowledgeBaseHandler.GetVersion)
+	admin.GET("/kb/versions/:id/chunks", knowledgeBaseHandler.ListChunks)
+	admin.POST("/kb/versions/:id/activate", knowledgeBaseHandler.Activate)
+	admin.DELETE("/kb/versions/:id", knowledgeBaseHandler.Delete)
 
 	// graceful shutdown
 	quit := make(chan os.Signal, 1)
@@ -110,4 +174,66 @@ func main() {
 	if err := e.Start(":" + cfg.Server.Port); err != nil {
 		log.Fatalf("failed to start server: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+// purge expired llm cache entries and audit content past retention, runs until ctx is done
+func runMaintenance(ctx context.Context, cacheRepo domain.LLMCacheRepository, llmCallRepo domain.LLMCallRepository, retentionDays int) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		if cacheRepo != nil {
+			if n, err := cacheRepo.DeleteExpired(ctx); err != nil {
+				log.Printf("failed to purge llm cache: %v", err)
+			} else if n > 0 {
+				log.Printf("purged %d expired llm cache entries", n)
+			}
+		}
+
+		if retentionDays > 0 {
+			before := time.Now().AddDate(0, 0, -retentionDays)
+			if n, err := llmCallRepo.PurgeContentBefore(ctx, before); err != nil {
+				log.Printf("failed to purge llm audit content: %v", err)
+			} else if n > 0 {
+				log.Printf("purged prompt/response content of %d llm calls", n)
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// re-enqueue staging versions whose ingest job was lost, at startup and every staleAfter until ctx is done.
+// running ingests refresh a heartbeat, versions without one for staleAfter are failed
+func runIngestRecovery(ctx context.Context, kbUsecase usecase.KnowledgeBaseUsecase, jobQueue service.JobQueue, staleAfter time.Duration) {
+	if staleAfter <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(staleAfter)
+	defer ticker.Stop()
+
+	for {
+		versions, err := kbUsecase.RecoverStaging(ctx, staleAfter)
+		if err != nil {
+			log.Printf("failed to recover staging knowledge base versions: %v", err)
+		}
+		for _, version := range versions {
+			if err := jobQueue.Enqueue(service.Job{ID: version.ID, Kind: service.JobIngest}); err != nil {
+				kbUsecase.FailIngest(ctx, version.ID, err)
+				continue
+			}
+			log.Printf("re-enqueued ingest of knowledge base version %s", version.ID)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -12,6 +14,13 @@ type Config struct {
     Storage  StorageConfig
     Gemini GeminiConfig
     Queue QueueConfig
+    Evaluation EvaluationConfig
+    Prompt PromptConfig
+    Pricing PricingConfig
+    LLMCache LLMCacheConfig
+    Audit AuditConfig
+    RateLimit RateLimitConfig
+    Retrieval RetrievalConfig
 }
 
 type ServerConfig struct {
@@ -38,6 +47,75 @@ type GeminiConfig struct {
     Temperature float32
     MaxTokens int32
     Dimension *int32
+    FallbackModels []string // tried in order when the primary model fails with a retryable error
+    BreakerThreshold int
+    BreakerCooldown int // seconds
+    CV StageConfig
+    Project StageConfig
+    Summary StageConfig
+}
+
+// model params for one pipeline stage, unset values inherit the gemini defaults
+type StageConfig struct {
+    Model string
+    Temperature float32
+    MaxTokens int32
+}
+
+type EvaluationConfig struct {
+    ScoreTolerance float64
+    Samples int
+    ParallelSamples bool
+    Aggregation string
+    VarianceThreshold float64
+    DocumentTokenBudget int // evaluation prompt size, documents that would overflow it are map-reduced
+    SectionTokens int
+}
+
+type PromptConfig struct {
+    Dir string
+    Version string
+}
+
+// usd per 1M tokens
+type ModelPrice struct {
+    Input float64
+    Output float64
+}
+
+type PricingConfig struct {
+    Prices map[string]ModelPrice
+}
+
+type LLMCacheConfig struct {
+    Enabled bool
+    TTL int
+}
+
+type AuditConfig struct {
+    Redact bool
+    RetentionDays int
+    AdminAPIKey string
+}
+
+// gemini requests and tokens per minute, 0 disables the limit
+type RateLimitConfig struct {
+    RPM int
+    TPM int
+    Shared bool // coordinate replicas through postgres
+}
+
+// rag retrieval, hybrid merges full-text and vector rankings with reciprocal rank fusion
+type RetrievalConfig struct {
+    Mode string // vector or hybrid
+    VectorWeight float64
+    LexicalWeight float64
+    RRFK int // rank constant, higher flattens the contribution of top ranks
+    Candidates int // rows taken from each ranking before fusion, also the mmr candidate pool
+    MinSimilarity float64
+    MMR bool // re-select results with maximal marginal relevance
+    MMRLambda float64 // 1 is pure relevance, 0 is pure diversity
+    MetadataFilter string // applied to every search, e.g. pin a rubric version, parsed by domain.ParseMetadataFilter
 }
 
 type QueueConfig struct {
@@ -49,11 +127,70 @@ type QueueConfig struct {
 func Load() (*Config, error) {
     viper.SetConfigFile(".env")
     viper.AutomaticEnv()
+    viper.SetDefault("LLM_PRICES", "gemini-2.5-flash-lite=0.10/0.40,gemini-2.5-flash=0.30/2.50")
+    viper.SetDefault("LLM_CACHE_ENABLED", true)
+    viper.SetDefault("LLM_CACHE_TTL", 86400)
+    viper.SetDefault("LLM_AUDIT_REDACT", false)
+    viper.SetDefault("LLM_AUDIT_RETENTION_DAYS", 365)
+    viper.SetDefault("LLM_RPM", 0)
+    viper.SetDefault("LLM_TPM", 0)
+    viper.SetDefault("LLM_RATE_LIMIT_SHARED", false)
+    viper.SetDefault("RETRIEVAL_MODE", "hybrid")
+    viper.SetDefault("RETRIEVAL_VECTOR_WEIGHT", 1.0)
+    viper.SetDefault("RETRIEVAL_LEXICAL_WEIGHT", 1.0)
+    viper.SetDefault("RETRIEVAL_RRF_K", 60)
+    viper.SetDefault("RETRIEVAL_CANDIDATES", 50)
+    viper.SetDefault("RETRIEVAL_MIN_SIMILARITY", 0.0)
+    viper.SetDefault("RETRIEVAL_MMR", true)
+    viper.SetDefault("RETRIEVAL_MMR_LAMBDA", 0.7)
+    viper.SetDefault("PROMPT_DIR", "./prompts")
+    viper.SetDefault("PROMPT_VERSION", "v1")
+    viper.SetDefault("LLM_BREAKER_THRESHOLD", 5)
+    viper.SetDefault("LLM_BREAKER_COOLDOWN", 60)
+    viper.SetDefault("SCORE_TOLERANCE", 0.1)
+    viper.SetDefault("EVAL_SAMPLES", 1)
+    viper.SetDefault("EVAL_PARALLEL_SAMPLES", true)
+    viper.SetDefault("EVAL_AGGREGATION", "median")
+    viper.SetDefault("EVAL_VARIANCE_THRESHOLD", 0.1)
+    viper.SetDefault("EVAL_DOCUMENT_TOKEN_BUDGET", 12000)
+    viper.SetDefault("EVAL_SECTION_TOKENS", 3000)
     
     if err := viper.ReadInConfig(); err != nil {
         return nil, err
     }
     
+    prices, err := parsePrices(viper.GetString("LLM_PRICES"))
+    if err != nil {
+        return nil, err
+    }
+
+    dimension, err := parseDimension()
+    if err != nil {
+        return nil, err
+    }
+
+    // any other mode would silently fall back to vector search
+    retrievalMode := viper.GetString("RETRIEVAL_MODE")
+    if retrievalMode != "vector" && retrievalMode != "hybrid" {
+        return nil, fmt.Errorf("invalid RETRIEVAL_MODE %q, expected vector or hybrid", retrievalMode)
+    }
+
+
+    gemini := GeminiConfig{
+        APIKey: viper.GetString("GEMINI_APIKEY"),
+        Model: viper.GetString("GEMINI_MODEL"),
+        EmbeddingModel: viper.GetString("GEMINI_EMBEDDING_MODEL"),
+        Temperature: float32(viper.GetFloat64("GEMINI_TEMPERATURE")),
+        MaxTokens: viper.GetInt32("GEMINI_MAX_TOKENS"),
+        Dimension: dimension,
+        FallbackModels: parseList(viper.GetString("GEMINI_FALLBACK_MODELS")),
+        BreakerThreshold: viper.GetInt("LLM_BREAKER_THRESHOLD"),
+        BreakerCooldown: viper.GetInt("LLM_BREAKER_COOLDOWN"),
+    }
+    gemini.CV = parseStageConfig("GEMINI_CV", gemini)
+    gemini.Project = parseStageConfig("GEMINI_PROJECT", gemini)
+    gemini.Summary = parseStageConfig("GEMINI_SUMMARY", gemini)
+
     config := &Config{
         Server: ServerConfig{
             Port: viper.GetString("SERVER_PORT"),
@@ -69,29 +206,134 @@ func Load() (*Config, error) {
         Storage: StorageConfig{
             UploadDir: viper.GetString("UPLOAD_DIR"),
         },
-        Gemini: GeminiConfig{
-            APIKey: viper.GetString("GEMINI_APIKEY"),
-            Model: viper.GetString("GEMINI_MODEL"),
-            EmbeddingModel: viper.GetString("GEMINI_EMBEDDING_MODEL"),
-            Temperature: float32(viper.GetFloat64("GEMINI_TEMPERATURE")),
-			MaxTokens: viper.GetInt32("GEMINI_MAX_TOKENS"),
-            Dimension: parseDimensionPtr(),
-        },
+        Gemini: gemini,
         Queue: QueueConfig {
         	WorkerCount: viper.GetInt("WORKER_COUNT"),
         	QueueSize: viper.GetInt("JOB_QUEUE_SIZE"),
         	JobTimeout: viper.GetInt("JOB_TIMEOUT"),
         },
+        Evaluation: EvaluationConfig{
+            ScoreTolerance: viper.GetFloat64("SCORE_TOLERANCE"),
+            Samples: viper.GetInt("EVAL_SAMPLES"),
+            ParallelSamples: viper.GetBool("EVAL_PARALLEL_SAMPLES"),
+            Aggregation: viper.GetString("EVAL_AGGREGATION"),
+            VarianceThreshold: viper.GetFloat64("EVAL_VARIANCE_THRESHOLD"),
+            DocumentTokenBudget: viper.GetInt("EVAL_DOCUMENT_TOKEN_BUDGET"),
+            SectionTokens: viper.GetInt("EVAL_SECTION_TOKENS"),
+        },
+        Prompt: PromptConfig{
+            Dir: viper.GetString("PROMPT_DIR"),
+            Version: viper.GetString("PROMPT_VERSION"),
+        },
+        Pricing: PricingConfig{
+            Prices: prices,
+        },
+        LLMCache: LLMCacheConfig{
+            Enabled: viper.GetBool("LLM_CACHE_ENABLED"),
+            TTL: viper.GetInt("LLM_CACHE_TTL"),
+        },
+        Audit: AuditConfig{
+            Redact: viper.GetBool("LLM_AUDIT_REDACT"),
+            RetentionDays: viper.GetInt("LLM_AUDIT_RETENTION_DAYS"),
+            AdminAPIKey: viper.GetString("ADMIN_API_KEY"),
+        },
+        RateLimit: RateLimitConfig{
+            RPM: viper.GetInt("LLM_RPM"),
+            TPM: viper.GetInt("LLM_TPM"),
+            Shared: viper.GetBool("LLM_RATE_LIMIT_SHARED"),
+        },
+        Retrieval: RetrievalConfig{
+            Mode: retrievalMode,
+            VectorWeight: viper.GetFloat64("RETRIEVAL_VECTOR_WEIGHT"),
+            LexicalWeight: viper.GetFloat64("RETRIEVAL_LEXICAL_WEIGHT"),
+            RRFK: viper.GetInt("RETRIEVAL_RRF_K"),
+            Candidates: viper.GetInt("RETRIEVAL_CANDIDATES"),
+            MinSimilarity: viper.GetFloat64("RETRIEVAL_MIN_SIMILARITY"),
+            MMR: viper.GetBool("RETRIEVAL_MMR"),
+            MMRLambda: viper.GetFloat64("RETRIEVAL_MMR_LAMBDA"),
+            MetadataFilter: viper.GetString("RETRIEVAL_METADATA_FILTER"),
+        },
     }
     
     return config, nil
 }
 
-func parseDimensionPtr() *int32 {
-    dimension := viper.GetString("GEMINI_DIMENSION")
+// embedding dimension drives the vector column, a missing or invalid value is a config error
+func parseDimension() (*int32, error) {
+    dimension := strings.TrimSpace(viper.GetString("GEMINI_DIMENSION"))
+    if dimension == "" {
+        return nil, fmt.Errorf("GEMINI_DIMENSION is required")
+    }
+
+    val, err := strconv.ParseInt(dimension, 10, 32)
+    if err != nil || val <= 0 {
+        return nil, fmt.Errorf("invalid GEMINI_DIMENSION %q, expected a positive integer", dimension)
+    }
 
-    val, _ := strconv.Atoi(dimension)
     val32 := int32(val)
+    return &val32, nil
+}
 
-    return &val32
-}
\ No newline at end of file
+// read <prefix>_MODEL, <prefix>_TEMPERATURE and <prefix>_MAX_TOKENS, falling back to the base gemini config
+func parseStageConfig(prefix string, base GeminiConfig) StageConfig {
+    stage := StageConfig{
+        Model: base.Model,
+        Temperature: base.Temperature,
+        MaxTokens: base.MaxTokens,
+    }
+
+    if model := viper.GetString(prefix + "_MODEL"); model != "" {
+        stage.Model = model
+    }
+    if viper.GetString(prefix+"_TEMPERATURE") != "" {
+        stage.Temperature = float32(viper.GetFloat64(prefix + "_TEMPERATURE"))
+    }
+    if viper.GetString(prefix+"_MAX_TOKENS") != "" {
+        stage.MaxTokens = viper.GetInt32(prefix + "_MAX_TOKENS")
+    }
+
+    return stage
+}
+
+// parse comma separated list, blanks dropped
+func parseList(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
+}
+
+// parse "model=input/output,model=input/output" price list
+func parsePrices(value string) (map[string]ModelPrice, error) {
+    prices := make(map[string]ModelPrice)
+
+    for _, entry := range strings.Split(value, ",") {
+        entry = strings.TrimSpace(entry)
+        if entry == "" {
+            continue
+        }
+
+        model, price, ok := strings.Cut(entry, "=")
+        inputStr, outputStr, okPrice := strings.Cut(price, "/")
+        if !ok || !okPrice {
+            return nil, fmt.Errorf("invalid LLM_PRICES entry %q, expected model=input/output", entry)
+        }
+
+        input, err := strconv.ParseFloat(strings.TrimSpace(inputStr), 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid input price for %s: %w", model, err)
+        }
+
+        output, err := strconv.ParseFloat(strings.TrimSpace(outputStr), 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid output price for %s: %w", model, err)
+        }
+
+        prices[strings.TrimSpace(model)] = ModelPrice{input, output}
+    }
+
+    return prices, nil
+}
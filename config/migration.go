@@ -3,12 +3,15 @@ package config
 import (
 	"fmt"
 	"log"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/sawalreverr/cv-reviewer/internal/domain"
 	"gorm.io/gorm"
 )
 
-func RunMigration(db *gorm.DB) error {
+func RunMigration(db *gorm.DB, dimension int) error {
 	log.Println("running database migration...")
 
 	// enable pgvector extension
@@ -18,9 +21,14 @@ func RunMigration(db *gorm.DB) error {
 
 	entities := []interface{}{
 		&domain.Document{},
+		&domain.Position{},
 		&domain.EvaluationJob{},
 		&domain.EvaluationResult{},
+		&domain.KBVersion{},
 		&domain.VectorDocument{},
+		&domain.LLMCall{},
+		&domain.LLMCacheEntry{},
+		&domain.RateLimitBucket{},
 	}
 
 	// auto migrate tables
@@ -28,8 +36,26 @@ func RunMigration(db *gorm.DB) error {
 		return fmt.Errorf("failed to run migrations: %w", err)
 	}
 
+	// embedding column is sized from config, automigrate skips it
+	if err := db.Exec(fmt.Sprintf("ALTER TABLE vector_documents ADD COLUMN IF NOT EXISTS embedding vector(%d)", dimension)).Error; err != nil {
+		return fmt.Errorf("failed to create embedding column: %w", err)
+	}
+
+	// full-text column for hybrid retrieval (kept in sync by postgres) and metadata filter index
+	if err := createSearchIndexes(db); err != nil {
+		return err
+	}
+
+	// chunks ingested before positions and knowledge base versions existed
+	if err := backfillLegacyPositions(db); err != nil {
+		return err
+	}
+	if err := backfillLegacyVersions(db); err != nil {
+		return err
+	}
+
 	// create vector index (hnsw/ivfflat)
-	if err := createVectorIndex(db); err != nil {
+	if err := CreateVectorIndex(db); err != nil {
 		log.Printf("failed to create vector index: %v", err)
 	}
 
@@ -37,7 +63,89 @@ func RunMigration(db *gorm.DB) error {
 	return nil
 }
 
-func createVectorIndex(db *gorm.DB) error {
+// chunks ingested before positions existed have no position and would never be retrieved,
+// they are assigned to the default position (created when missing)
+func backfillLegacyPositions(db *gorm.DB) error {
+	var legacy int64
+	if err := db.Model(&domain.VectorDocument{}).Where("position_id IS NULL").Count(&legacy).Error; err != nil {
+		return fmt.Errorf("failed to count legacy chunks: %w", err)
+	}
+	if legacy == 0 {
+		return nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var position domain.Position
+		err := tx.Where("slug = ?", domain.DefaultPositionSlug).First(&position).Error
+		if err == gorm.ErrRecordNotFound {
+			position = *domain.NewPosition(domain.DefaultPositionSlug, "Default Position", "knowledge base ingested before positions existed")
+			err = tx.Create(&position).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		log.Printf("assigning %d legacy chunks to position %s...", legacy, position.Slug)
+		return tx.Model(&domain.VectorDocument{}).Where("position_id IS NULL").Update("position_id", position.ID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill legacy chunk positions: %w", err)
+	}
+
+	return nil
+}
+
+// chunks ingested before versioning have no version and would never be searched,
+// every (position, document type) group becomes a version of its own. it is activated
+// unless the document already has an active version, then it is kept as archived
+func backfillLegacyVersions(db *gorm.DB) error {
+	var groups []struct {
+		PositionID uuid.UUID
+		DocType domain.DocumentType
+		Count int
+	}
+	query := db.Model(&domain.VectorDocument{}).Select("position_id, doc_type, COUNT(*) as count").Where("kb_version_id IS NULL AND position_id IS NOT NULL").Group("position_id, doc_type")
+	if err := query.Scan(&groups).Error; err != nil {
+		return fmt.Errorf("failed to find legacy chunks: %w", err)
+	}
+
+	for _, group := range groups {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var latest int
+			var active int64
+			if err := tx.Model(&domain.KBVersion{}).Select("COALESCE(MAX(version), 0)").Where("position_id = ? AND doc_type = ?", group.PositionID, group.DocType).Scan(&latest).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&domain.KBVersion{}).Where("position_id = ? AND doc_type = ? AND status = ?", group.PositionID, group.DocType, domain.KBActive).Count(&active).Error; err != nil {
+				return err
+			}
+
+			version := domain.NewKBVersion(group.PositionID, group.DocType, "legacy", nil, nil, domain.ChunkingOptions{})
+			version.Version = latest + 1
+			version.ChunkCount = group.Count
+			version.Report.Added = group.Count
+			version.Status = domain.KBArchived
+			if active == 0 {
+				now := time.Now()
+				version.Status = domain.KBActive
+				version.ActivatedAt = &now
+			}
+			if err := tx.Create(version).Error; err != nil {
+				return err
+			}
+
+			log.Printf("moving %d legacy %s chunks into %s version %d", group.Count, group.DocType, version.Status, version.Version)
+			return tx.Model(&domain.VectorDocument{}).Where("kb_version_id IS NULL AND position_id = ? AND doc_type = ?", group.PositionID, group.DocType).Update("kb_version_id", version.ID).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to backfill legacy %s version: %w", group.DocType, err)
+		}
+	}
+
+	return nil
+}
+
+func CreateVectorIndex(db *gorm.DB) error {
 	// check if index already exists
 	var indexExists bool
 	checkQuery := `
@@ -67,7 +175,7 @@ func createVectorIndex(db *gorm.DB) error {
 		USING ivfflat (embedding vector_cosine_ops)
 		WITH (lists = 100)
 		`
-	if err := db.Exec(createIndexQuery); err != nil {
+	if err := db.Exec(createIndexQuery).Error; err != nil {
 		log.Println("IVFFlat index creation failed (might need more data), trying basic index...")
 
 		// fallback: create basic vector index without IVFFlat
@@ -85,13 +193,96 @@ func createVectorIndex(db *gorm.DB) error {
 	return nil
 }
 
+func createSearchIndexes(db *gorm.DB) error {
+	// 'simple' only lowercases, english stemming and stopwords mangle indonesian and malay documents.
+	// columns generated with 'english' by an older migration are rebuilt
+	var expression string
+	if err := db.Raw("SELECT COALESCE(generation_expression, '') FROM information_schema.columns WHERE table_name = 'vector_documents' AND column_name = 'content_tsv'").Scan(&expression).Error; err != nil {
+		return fmt.Errorf("failed to read full-text column: %w", err)
+	}
+	if strings.Contains(expression, "english") {
+		log.Println("rebuilding full-text column with the simple configuration...")
+		if err := db.Exec("ALTER TABLE vector_documents DROP COLUMN content_tsv").Error; err != nil {
+			return fmt.Errorf("failed to drop full-text column: %w", err)
+		}
+	}
+
+	columnQuery := `
+		ALTER TABLE vector_documents
+		ADD COLUMN IF NOT EXISTS content_tsv tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED
+	`
+	if err := db.Exec(columnQuery).Error; err != nil {
+		return fmt.Errorf("failed to create full-text column: %w", err)
+	}
+
+	indexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_vector_documents_content_tsv
+		ON vector_documents
+		USING gin (content_tsv)
+	`
+	if err := db.Exec(indexQuery).Error; err != nil {
+		return fmt.Errorf("failed to create full-text index: %w", err)
+	}
+
+	// expression index for lookups by content hash, the jsonb_path_ops index does not serve ->> equality
+	hashIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_vector_documents_content_hash
+		ON vector_documents ((metadata ->> 'content_hash'))
+	`
+	if err := db.Exec(hashIndexQuery).Error; err != nil {
+		return fmt.Errorf("failed to create content hash index: %w", err)
+	}
+
+	// metadata filters use jsonb containment
+	metadataIndexQuery := `
+		CREATE INDEX IF NOT EXISTS idx_vector_documents_metadata
+		ON vector_documents
+		USING gin (metadata jsonb_path_ops)
+	`
+	if err := db.Exec(metadataIndexQuery).Error; err != nil {
+		return fmt.Errorf("failed to create metadata index: %w", err)
+	}
+
+	return nil
+}
+
+// drop the vector index and recreate the embedding column with a new dimension,
+// existing chunks keep their content and are left with a null embedding to re-embed.
+// every version loses its embeddings at once, retrieval is down until the re-embed finishes
+func RebuildEmbeddingColumn(db *gorm.DB, dimension int) error {
+	log.Printf("WARNING: dropping every stored embedding, searches return nothing until the re-embed finishes")
+	log.Printf("rebuilding embedding column as vector(%d)...", dimension)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DROP INDEX IF EXISTS idx_vector_documents_embedding").Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("ALTER TABLE vector_documents DROP COLUMN IF EXISTS embedding").Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("ALTER TABLE vector_documents ADD COLUMN embedding vector(%d)", dimension)).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to rebuild embedding column: %w", err)
+	}
+
+	log.Println("embedding column rebuilt!")
+	return nil
+}
+
 func DropAllTables(db *gorm.DB) error {
 	log.Println("dropping all tables...")
 
 	entities := []interface{}{
+		&domain.LLMCacheEntry{},
+		&domain.RateLimitBucket{},
+		&domain.LLMCall{},
 		&domain.VectorDocument{},
+		&domain.KBVersion{},
 		&domain.EvaluationResult{},
 		&domain.EvaluationJob{},
+		&domain.Position{},
 		&domain.Document{},
 	}
 
@@ -18,6 +18,29 @@ const (
 	ProjectRubric DocumentType = "project_rubric"
 )
 
+// document types that make up a position knowledge base
+var KnowledgeBaseTypes = []DocumentType{JobDescription, CaseStudyBrief, CVRubric, ProjectRubric}
+
+// knowledge base types without an active chunk, an evaluation needs all of them
+func MissingKnowledgeBaseTypes(counts map[DocumentType]int64) []DocumentType {
+	var missing []DocumentType
+	for _, kbType := range KnowledgeBaseTypes {
+		if counts[kbType] == 0 {
+			missing = append(missing, kbType)
+		}
+	}
+	return missing
+}
+
+func IsKnowledgeBaseType(docType DocumentType) bool {
+	for _, kbType := range KnowledgeBaseTypes {
+		if kbType == docType {
+			return true
+		}
+	}
+	return false
+}
+
 // entity
 type Document struct {
 	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
@@ -2,6 +2,8 @@ package domain
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,13 +18,51 @@ const (
 	StatusFailed JobStatus = "failed"
 )
 
+// how a candidate document was fed to the evaluation prompt
+type TextStrategy string
+
+const (
+	TextFull TextStrategy = "full"
+	TextMapReduce TextStrategy = "map_reduce" // split into sections, summarised per section, then combined
+)
+
+// instruction-like content found in a candidate document
+type InjectionFinding struct {
+	Source string `json:"source"` // cv or project_report
+	Pattern string `json:"pattern"`
+	Excerpt string `json:"excerpt"`
+}
+
+type InjectionFindings []InjectionFinding
+
+// impl sql.Scanner
+func (f *InjectionFindings) Scan(value interface{}) error {
+	return scanJSON(value, f)
+}
+
+// impl driver.Valuer
+func (f InjectionFindings) Value() (driver.Value, error) {
+	if f == nil {
+		return nil, nil
+	}
+	return json.Marshal(f)
+}
+
 // entity
 type EvaluationJob struct {
 	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	PositionID uuid.UUID `gorm:"type:uuid;index" json:"position_id"` // knowledge base used for retrieval
+	KBVersions KBVersionPins `gorm:"type:jsonb" json:"kb_versions,omitempty"` // pinned when processing starts
 	JobTitle string `gorm:"type:text;not null" json:"job_title"`
 	CVID uuid.UUID `gorm:"type:uuid;not null;index" json:"cv_id"`
 	ProjectReportID uuid.UUID `gorm:"type:uuid;not null" json:"project_report_id"`
 	Status JobStatus `gorm:"type:text;not null;index" json:"status"`
+	SkipCache bool `gorm:"not null;default:false" json:"skip_cache"` // bypass llm response cache
+	FeedbackLanguage string `gorm:"type:text;not null;default:'en'" json:"feedback_language"` // iso 639-1
+	InjectionRisk bool `gorm:"not null;default:false;index" json:"injection_risk"`
+	InjectionFindings InjectionFindings `gorm:"type:jsonb" json:"injection_findings,omitempty"`
+	CVTextStrategy TextStrategy `gorm:"type:text" json:"cv_text_strategy,omitempty"`
+	ProjectTextStrategy TextStrategy `gorm:"type:text" json:"project_text_strategy,omitempty"`
 	ErrorMessage *string `gorm:"type:text;default:null" json:"error_message,omitempty"` // optional, bisa nil
 	StartedAt *time.Time `gorm:"type:timestamptz;default:null" json:"started_at,omitempty"` // optional, bisa nil
 	CompletedAt *time.Time `gorm:"type:timestamptz;default:null" json:"completed_at,omitempty"` // optional, bisa nil
@@ -30,18 +70,27 @@ type EvaluationJob struct {
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"-"`
 }
 
-func NewEvaluationJob(jobTitle string, cvID, projectReportID uuid.UUID) *EvaluationJob {
+func NewEvaluationJob(positionID uuid.UUID, jobTitle string, cvID, projectReportID uuid.UUID, skipCache bool, feedbackLanguage string) *EvaluationJob {
 	return &EvaluationJob{
 		ID: uuid.New(),
+		PositionID: positionID,
 		JobTitle: jobTitle,
 		CVID: cvID,
 		ProjectReportID: projectReportID,
 		Status: StatusQueued,
+		SkipCache: skipCache,
+		FeedbackLanguage: feedbackLanguage,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 }
 
+// record prompt injection detections, the job still runs but is flagged
+func (ej *EvaluationJob) FlagInjection(findings []InjectionFinding) {
+	ej.InjectionFindings = findings
+	ej.InjectionRisk = len(findings) > 0
+}
+
 func (ej *EvaluationJob) MarkProcessing() {
 	now := time.Now()
 	ej.Status = StatusProcessing
@@ -2,44 +2,190 @@ package domain
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// single rubric criterion scored by the llm
+type CriterionScore struct {
+	Name string `json:"name"`
+	Weight float64 `json:"weight"`
+	Score float64 `json:"score"`
+	Justification string `json:"justification"`
+}
+
+type CriterionScores []CriterionScore
+
+// impl sql.Scanner
+func (c *CriterionScores) Scan(value interface{}) error {
+	return scanJSON(value, c)
+}
+
+// impl driver.Valuer
+func (c CriterionScores) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+const (
+	EvidenceStrength = "strength"
+	EvidenceGap = "gap"
+)
+
+// feedback claim backed by a quote, start/end are character offsets in the extracted text
+type Evidence struct {
+	Kind string `json:"kind"`
+	Claim string `json:"claim"`
+	Quote string `json:"quote"`
+	Verified bool `json:"verified"` // quote found in the document
+	Start *int `json:"start,omitempty"` // nil when the quote was not found, 0 is a valid offset
+	End *int `json:"end,omitempty"`
+}
+
+type EvidenceList []Evidence
+
+// impl sql.Scanner
+func (e *EvidenceList) Scan(value interface{}) error {
+	return scanJSON(value, e)
+}
+
+// impl driver.Valuer
+func (e EvidenceList) Value() (driver.Value, error) {
+	if e == nil {
+		return nil, nil
+	}
+	return json.Marshal(e)
+}
+
+type Recommendation string
+
+const (
+	RecommendationStrongHire Recommendation = "strong_hire"
+	RecommendationHire Recommendation = "hire"
+	RecommendationMaybe Recommendation = "maybe"
+	RecommendationPass Recommendation = "pass"
+)
+
+// accepts "Strong Hire", "strong-hire", "strong_hire", ...
+func ParseRecommendation(value string) (Recommendation, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	normalized = strings.NewReplacer(" ", "_", "-", "_").Replace(normalized)
+
+	switch rec := Recommendation(normalized); rec {
+	case RecommendationStrongHire, RecommendationHire, RecommendationMaybe, RecommendationPass:
+		return rec, true
+	}
+	return "", false
+}
+
+type StringList []string
+
+// impl sql.Scanner
+func (s *StringList) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// impl driver.Valuer
+func (s StringList) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
+// model params that actually answered a stage, models can differ when a fallback served some calls
+type StageParams struct {
+	Stage LLMStage `json:"stage"`
+	Models []string `json:"models"`
+	Temperature float32 `json:"temperature"`
+	MaxTokens int32 `json:"max_tokens"`
+}
+
+type StageParamsList []StageParams
+
+// impl sql.Scanner
+func (s *StageParamsList) Scan(value interface{}) error {
+	return scanJSON(value, s)
+}
+
+// impl driver.Valuer
+func (s StageParamsList) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return json.Marshal(s)
+}
+
 // entity
 type EvaluationResult struct {
 	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
 	JobID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"job_id"`
 	CVMatchRate float64 `gorm:"not null" json:"cv_match_rate"`
 	CVFeedback string `gorm:"type:text;not null" json:"cv_feedback"`
+	CVCriteria CriterionScores `gorm:"type:jsonb" json:"cv_criteria"`
+	CVEvidence EvidenceList `gorm:"type:jsonb" json:"cv_evidence"`
 	ProjectScore float64 `gorm:"not null" json:"project_score"`
 	ProjectFeedback string `gorm:"type:text;not null" json:"project_feedback"`
+	ProjectCriteria CriterionScores `gorm:"type:jsonb" json:"project_criteria"`
+	ProjectEvidence EvidenceList `gorm:"type:jsonb" json:"project_evidence"`
 	OverallSummary string `gorm:"type:text;not null" json:"overall_summary"`
+	Recommendation Recommendation `gorm:"type:text;index" json:"recommendation"`
+	KeyStrengths StringList `gorm:"type:jsonb" json:"key_strengths"`
+	KeyRisks StringList `gorm:"type:jsonb" json:"key_risks"`
+	CVReportedMatchRate float64 `json:"cv_reported_match_rate"`
+	ProjectReportedScore float64 `json:"project_reported_score"`
+	ScoreMismatch bool `gorm:"not null;default:false" json:"score_mismatch"` // llm aggregate disagrees with computed score
+	SampleCount int `gorm:"not null;default:1" json:"sample_count"` // successful samples
+	CVMatchRateStdDev float64 `json:"cv_match_rate_stddev"`
+	ProjectScoreStdDev float64 `json:"project_score_stddev"`
+	NeedsReview bool `gorm:"not null;default:false;index" json:"needs_review"` // high variance across samples or failed samples
+	PromptVersion string `gorm:"type:text;index" json:"prompt_version"`
+	StageParams StageParamsList `gorm:"type:jsonb" json:"stage_params"`
+	CVLanguage string `gorm:"type:text" json:"cv_language"` // detected, iso 639-1, empty when unknown
+	ProjectLanguage string `gorm:"type:text" json:"project_language"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"-"`
 }
 
-func NewEvaluationResult(jobID uuid.UUID, cvMatchRate float64, cvFeedback string, projectScore float64, projectFeedback string, overallSummary string) *EvaluationResult {
+func NewEvaluationResult(jobID uuid.UUID, cvMatchRate float64, cvFeedback string, cvCriteria []CriterionScore, projectScore float64, projectFeedback string, projectCriteria []CriterionScore, overallSummary string) *EvaluationResult {
 	return &EvaluationResult{
 		ID: uuid.New(),
 		JobID: jobID,
 		CVMatchRate: cvMatchRate,
 		CVFeedback: cvFeedback,
+		CVCriteria: CriterionScores(cvCriteria),
 		ProjectScore: projectScore,
 		ProjectFeedback: projectFeedback,
+		ProjectCriteria: CriterionScores(projectCriteria),
 		OverallSummary: overallSummary,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 }
 
+// list filter, zero values are ignored
+type EvaluationResultFilter struct {
+	Recommendation Recommendation
+	Strength string // substring match on key strengths
+	Risk string // substring match on key risks
+	NeedsReview *bool
+	Limit int
+	Offset int
+}
+
 // contract
 type EvaluationResultRepository interface {
 	Create(ctx context.Context, result *EvaluationResult) error
 	FindByJobID(ctx context.Context, jobID uuid.UUID) (*EvaluationResult, error)
+	List(ctx context.Context, filter EvaluationResultFilter) ([]*EvaluationResult, int64, error)
 }
 
 func (EvaluationResult) TableName() string {
 	return "evaluation_results"
-}
\ No newline at end of file
+}
@@ -0,0 +1,123 @@
+package domain
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type KBVersionStatus string
+
+const (
+	KBStaging KBVersionStatus = "staging" // chunks being written, never searched
+	KBActive KBVersionStatus = "active"
+	KBArchived KBVersionStatus = "archived" // replaced, kept for rollback
+	KBFailed KBVersionStatus = "failed"
+)
+
+type ChunkStrategy string
+
+const (
+	ChunkSentence ChunkStrategy = "sentence" // sentences packed up to size characters
+	ChunkFixed ChunkStrategy = "fixed" // size characters with overlap
+)
+
+// how a knowledge base document is split, zero values use sentence chunks of 1000 characters
+type ChunkingOptions struct {
+	Strategy ChunkStrategy `gorm:"type:text" json:"strategy,omitempty"`
+	Size int `json:"size,omitempty"`
+	Overlap int `json:"overlap,omitempty"`
+}
+
+// entity, one ingest of a knowledge base document for a position.
+// at most one version per position and document type is active
+type KBVersion struct {
+	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	PositionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_kb_versions_number" json:"position_id"`
+	DocType DocumentType `gorm:"type:text;not null;uniqueIndex:idx_kb_versions_number" json:"doc_type"`
+	Version int `gorm:"not null;uniqueIndex:idx_kb_versions_number" json:"version"`
+	Status KBVersionStatus `gorm:"type:text;not null;index" json:"status"`
+	Source string `gorm:"type:text" json:"source"` // ingested file
+	DocumentID *uuid.UUID `gorm:"type:uuid;default:null" json:"document_id,omitempty"` // uploaded through the api
+	Metadata JSONB `gorm:"type:jsonb" json:"metadata"` // copied into every chunk
+	Chunking ChunkingOptions `gorm:"embedded;embeddedPrefix:chunking_" json:"chunking"`
+	ChunkCount int `gorm:"not null;default:0" json:"chunk_count"`
+	Report IngestReport `gorm:"embedded;embeddedPrefix:chunks_" json:"report"`
+	ErrorMessage *string `gorm:"type:text;default:null" json:"error_message,omitempty"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	StartedAt *time.Time `gorm:"type:timestamptz;default:null" json:"started_at,omitempty"` // claimed by an ingest
+	HeartbeatAt *time.Time `gorm:"type:timestamptz;default:null;index" json:"-"` // refreshed while the ingest runs
+	ActivatedAt *time.Time `gorm:"type:timestamptz;default:null" json:"activated_at,omitempty"`
+}
+
+func NewKBVersion(positionID uuid.UUID, docType DocumentType, source string, documentID *uuid.UUID, metadata map[string]interface{}, chunking ChunkingOptions) *KBVersion {
+	return &KBVersion{
+		ID: uuid.New(),
+		PositionID: positionID,
+		DocType: docType,
+		Status: KBStaging,
+		Source: source,
+		DocumentID: documentID,
+		Metadata: JSONB(metadata),
+		Chunking: chunking,
+		CreatedAt: time.Now(),
+	}
+}
+
+// chunk diff of an ingest against the version that was active, by content hash.
+// unchanged chunks reuse their stored embedding, reembedded chunks kept their content but were embedded again
+type IngestReport struct {
+	Added int `gorm:"not null;default:0" json:"added"`
+	Unchanged int `gorm:"not null;default:0" json:"unchanged"`
+	Reembedded int `gorm:"not null;default:0" json:"reembedded"` // stored with another embedding model
+	Removed int `gorm:"not null;default:0" json:"removed"`
+}
+
+// knowledge base versions an evaluation job read, document type -> version id
+type KBVersionPins map[DocumentType]uuid.UUID
+
+// impl sql.Scanner
+func (p *KBVersionPins) Scan(value interface{}) error {
+	return scanJSON(value, p)
+}
+
+// impl driver.Valuer
+func (p KBVersionPins) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+// knowledge base a search reads, a pinned version wins over the active one
+type SearchScope struct {
+	PositionID uuid.UUID
+	Versions KBVersionPins
+}
+
+// contract
+type KBVersionRepository interface {
+	// stores a staging version with the next version number of its position and document type
+	CreateStaged(ctx context.Context, version *KBVersion) error
+	// archives the active version of the same position and document type and activates this one, in one transaction
+	Activate(ctx context.Context, id uuid.UUID) (*KBVersion, error)
+	Update(ctx context.Context, version *KBVersion) error
+	FindByID(ctx context.Context, id uuid.UUID) (*KBVersion, error)
+	FindActive(ctx context.Context, positionID uuid.UUID) ([]*KBVersion, error)
+	ListByPosition(ctx context.Context, positionID uuid.UUID) ([]*KBVersion, error)
+	// marks a staging version as being ingested, false when another ingest claimed it first
+	Claim(ctx context.Context, version *KBVersion) (bool, error)
+	Heartbeat(ctx context.Context, id uuid.UUID) error
+	// staging versions never claimed since before the given time, or whose heartbeat stopped before it
+	ListStale(ctx context.Context, before time.Time) ([]*KBVersion, error)
+	// a queued or processing evaluation job pinned the version
+	IsPinned(ctx context.Context, version *KBVersion) (bool, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+func (KBVersion) TableName() string {
+	return "kb_versions"
+}
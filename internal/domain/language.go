@@ -0,0 +1,23 @@
+package domain
+
+import "strings"
+
+// feedback is written in english unless the request asks otherwise
+const DefaultFeedbackLanguage = "en"
+
+// iso 639-1 codes we detect and accept as feedback language
+var languageNames = map[string]string{
+	"en": "English",
+	"id": "Indonesian",
+	"ms": "Malay",
+	"nl": "Dutch",
+	"de": "German",
+	"fr": "French",
+	"es": "Spanish",
+	"pt": "Portuguese",
+}
+
+func LanguageName(code string) (string, bool) {
+	name, ok := languageNames[strings.ToLower(strings.TrimSpace(code))]
+	return name, ok
+}
@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// entity, cached llm response addressed by hash of model, params and prompt
+type LLMCacheEntry struct {
+	Key string `gorm:"type:text;primary_key" json:"key"`
+	Model string `gorm:"type:text;not null" json:"model"`
+	Response string `gorm:"type:text;not null" json:"response"`
+	ExpiresAt time.Time `gorm:"type:timestamptz;not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
+}
+
+func NewLLMCacheEntry(key, model, response string, ttl time.Duration) *LLMCacheEntry {
+	return &LLMCacheEntry{
+		Key: key,
+		Model: model,
+		Response: response,
+		ExpiresAt: time.Now().Add(ttl),
+		CreatedAt: time.Now(),
+	}
+}
+
+// contract
+type LLMCacheRepository interface {
+	Get(ctx context.Context, key string) (*LLMCacheEntry, error)
+	Set(ctx context.Context, entry *LLMCacheEntry) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+func (LLMCacheEntry) TableName() string {
+	return "llm_cache"
+}
@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type LLMStage string
+
+const (
+	StageCVEvaluation LLMStage = "cv_evaluation"
+	StageProjectEvaluation LLMStage = "project_evaluation"
+	StageFinalSummary LLMStage = "final_summary"
+	StageSectionSummary LLMStage = "section_summary" // map step for documents over the token budget
+	StageTokenCount LLMStage = "token_count" // count tokens request, no output and no cost
+)
+
+// entity, one row per llm request made while processing a job
+type LLMCall struct {
+	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	JobID uuid.UUID `gorm:"type:uuid;not null;index" json:"job_id"`
+	Stage LLMStage `gorm:"type:text;not null;index" json:"stage"`
+	Model string `gorm:"type:text;not null" json:"model"`
+	Temperature float32 `json:"temperature"`
+	MaxTokens int32 `json:"max_tokens"`
+	Prompt *string `gorm:"type:text" json:"prompt,omitempty"` // nil once purged by retention
+	Response *string `gorm:"type:text" json:"response,omitempty"` // raw model output
+	Error *string `gorm:"type:text" json:"error,omitempty"`
+	LatencyMs int64 `gorm:"not null;default:0" json:"latency_ms"`
+	InputTokens int32 `gorm:"not null;default:0" json:"input_tokens"`
+	OutputTokens int32 `gorm:"not null;default:0" json:"output_tokens"`
+	Cost float64 `gorm:"not null;default:0" json:"cost"` // usd
+	Cached bool `gorm:"not null;default:false" json:"cached"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+func NewLLMCall(stage LLMStage, model string, inputTokens, outputTokens int32, cost float64) *LLMCall {
+	return &LLMCall{
+		ID: uuid.New(),
+		Stage: stage,
+		Model: model,
+		InputTokens: inputTokens,
+		OutputTokens: outputTokens,
+		Cost: cost,
+		CreatedAt: time.Now(),
+	}
+}
+
+// aggregated usage, grouped by stage (per job) or by day
+type UsageSummary struct {
+	Stage LLMStage `json:"stage,omitempty"`
+	Day string `json:"day,omitempty"`
+	Jobs int64 `json:"jobs,omitempty"`
+	Calls int64 `json:"calls"`
+	CachedCalls int64 `json:"cached_calls"`
+	InputTokens int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+	Cost float64 `json:"cost"`
+}
+
+// contract
+type LLMCallRepository interface {
+	CreateBatch(ctx context.Context, calls []*LLMCall) error
+	FindByJobID(ctx context.Context, jobID uuid.UUID) ([]*LLMCall, error)
+	PurgeContentBefore(ctx context.Context, before time.Time) (int64, error)
+	SumByJob(ctx context.Context, jobID uuid.UUID) ([]*UsageSummary, error)
+	SumByDay(ctx context.Context, from, to time.Time) ([]*UsageSummary, error)
+}
+
+func (LLMCall) TableName() string {
+	return "llm_calls"
+}
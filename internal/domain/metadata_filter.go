@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type MetadataOp string
+
+const (
+	MetadataEq MetadataOp = "="
+	MetadataIn MetadataOp = "in"
+	MetadataGt MetadataOp = ">"
+	MetadataGte MetadataOp = ">="
+	MetadataLt MetadataOp = "<"
+	MetadataLte MetadataOp = "<="
+)
+
+// one condition on a metadata key, eq and in match strings, numbers and bools, ranges are numeric only
+type MetadataCondition struct {
+	Key string `json:"key"`
+	Op MetadataOp `json:"op"`
+	Values []interface{} `json:"values"`
+}
+
+// conditions are and-ed
+type MetadataFilter []MetadataCondition
+
+var metadataKeyRegex = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// range operators first so ">=" is not read as ">"
+var metadataOps = []MetadataOp{MetadataGte, MetadataLte, MetadataGt, MetadataLt, MetadataEq}
+
+// parse "version=1.0,source=cv_rubric|project_rubric,chunk_index>=3",
+// a "|" separated value list turns equality into IN
+func ParseMetadataFilter(expr string) (MetadataFilter, error) {
+	var filter MetadataFilter
+
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		condition, err := parseMetadataCondition(part)
+		if err != nil {
+			return nil, err
+		}
+		filter = append(filter, condition)
+	}
+
+	return filter, nil
+}
+
+func parseMetadataCondition(part string) (MetadataCondition, error) {
+	for _, op := range metadataOps {
+		key, value, ok := strings.Cut(part, string(op))
+		if !ok {
+			continue
+		}
+
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !metadataKeyRegex.MatchString(key) {
+			return MetadataCondition{}, fmt.Errorf("invalid metadata key %q", key)
+		}
+		if value == "" {
+			return MetadataCondition{}, fmt.Errorf("missing value for metadata key %q", key)
+		}
+
+		if op != MetadataEq {
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return MetadataCondition{}, fmt.Errorf("metadata range on %q needs a number, got %q", key, value)
+			}
+			return MetadataCondition{Key: key, Op: op, Values: []interface{}{number}}, nil
+		}
+
+		values := strings.Split(value, "|")
+		condition := MetadataCondition{Key: key, Op: MetadataEq}
+		if len(values) > 1 {
+			condition.Op = MetadataIn
+		}
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v == "" {
+				return MetadataCondition{}, fmt.Errorf("empty value in list for metadata key %q", key)
+			}
+			condition.Values = append(condition.Values, v)
+		}
+		return condition, nil
+	}
+
+	return MetadataCondition{}, fmt.Errorf("invalid metadata condition %q, expected key=value, key=a|b or key>=number", part)
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMetadataFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want MetadataFilter
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"equality", "version=1.0", MetadataFilter{{Key: "version", Op: MetadataEq, Values: []interface{}{"1.0"}}}, false},
+		{"gte is not read as gt", "chunk_index>=3", MetadataFilter{{Key: "chunk_index", Op: MetadataGte, Values: []interface{}{3.0}}}, false},
+		{"gt", "chunk_index>3", MetadataFilter{{Key: "chunk_index", Op: MetadataGt, Values: []interface{}{3.0}}}, false},
+		{"lte is not read as lt", "chunk_index<=2.5", MetadataFilter{{Key: "chunk_index", Op: MetadataLte, Values: []interface{}{2.5}}}, false},
+		{"lt", "chunk_index<2", MetadataFilter{{Key: "chunk_index", Op: MetadataLt, Values: []interface{}{2.0}}}, false},
+		{"pipe list becomes in", "source=cv_rubric|project_rubric", MetadataFilter{{Key: "source", Op: MetadataIn, Values: []interface{}{"cv_rubric", "project_rubric"}}}, false},
+		{"pipe list is trimmed", "source = a | b ", MetadataFilter{{Key: "source", Op: MetadataIn, Values: []interface{}{"a", "b"}}}, false},
+		{"conditions are and-ed", "version=1.0, chunk_index>=3", MetadataFilter{
+			{Key: "version", Op: MetadataEq, Values: []interface{}{"1.0"}},
+			{Key: "chunk_index", Op: MetadataGte, Values: []interface{}{3.0}},
+		}, false},
+		{"empty parts are skipped", "version=1.0,,", MetadataFilter{{Key: "version", Op: MetadataEq, Values: []interface{}{"1.0"}}}, false},
+
+		{"empty list entry", "source=a|", nil, true},
+		{"missing value", "version=", nil, true},
+		{"range needs a number", "chunk_index>=three", nil, true},
+		{"invalid key", "meta-data=1", nil, true},
+		{"reversed operator", "chunk_index=>3", nil, true},
+		{"no operator", "version", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMetadataFilter(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMetadataFilter(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMetadataFilter(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// position that chunks ingested before positions existed are assigned to
+const DefaultPositionSlug = "default"
+
+// entity, an open role with its own knowledge base (job description, case study, rubrics)
+type Position struct {
+	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Slug string `gorm:"type:text;not null;uniqueIndex" json:"slug"` // e.g. backend, frontend, data
+	Title string `gorm:"type:text;not null" json:"title"`
+	Description string `gorm:"type:text" json:"description"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"-"`
+}
+
+func NewPosition(slug, title, description string) *Position {
+	return &Position{
+		ID: uuid.New(),
+		Slug: slug,
+		Title: title,
+		Description: description,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+// contract
+type PositionRepository interface {
+	Create(ctx context.Context, position *Position) error
+	FindByID(ctx context.Context, id uuid.UUID) (*Position, error)
+	FindBySlug(ctx context.Context, slug string) (*Position, error)
+	List(ctx context.Context) ([]*Position, error)
+}
+
+func (Position) TableName() string {
+	return "positions"
+}
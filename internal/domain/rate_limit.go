@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// entity, token bucket state shared by every replica when rate limiting is coordinated through postgres
+type RateLimitBucket struct {
+	Name string `gorm:"type:text;primary_key" json:"name"`
+	Requests float64 `gorm:"not null;default:0" json:"requests"` // available requests
+	Tokens float64 `gorm:"not null;default:0" json:"tokens"` // available tokens
+	PausedUntil time.Time `gorm:"type:timestamptz" json:"paused_until"` // set from retry hints of the provider
+	RefilledAt time.Time `gorm:"type:timestamptz" json:"refilled_at"`
+}
+
+// contract
+type RateLimitRepository interface {
+	// run fn on the locked bucket row (created when missing) and save it afterwards
+	WithLock(ctx context.Context, name string, fn func(bucket *RateLimitBucket) error) error
+}
+
+func (RateLimitBucket) TableName() string {
+	return "llm_rate_limits"
+}
@@ -42,19 +42,39 @@ func (j JSONB) Value() (driver.Value, error) {
 	return json.Marshal(j)
 }
 
+// shared scanner for jsonb columns backed by slices
+func scanJSON(value interface{}, dest interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+
+	return json.Unmarshal(bytes, dest)
+}
+
 // entity
 type VectorDocument struct {
 	ID uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	PositionID uuid.UUID `gorm:"type:uuid;index" json:"position_id"`
+	KBVersionID uuid.UUID `gorm:"type:uuid;index" json:"kb_version_id"` // only chunks of active or pinned versions are searched
 	DocType DocumentType `gorm:"type:text;not null;index" json:"doc_type"`
 	Content string `gorm:"type:text;not null" json:"content"`
-	Embedding pgvector.Vector `gorm:"type:vector(768)" json:"-"` 
+	Embedding pgvector.Vector `gorm:"type:vector;-:migration" json:"-"` // column created from GEMINI_DIMENSION by the migration
 	Metadata  JSONB `gorm:"type:jsonb" json:"metadata"`
+	Similarity float64 `gorm:"->;-:migration" json:"similarity"` // cosine similarity to the query, only set by searches
+	Score float64 `gorm:"->;-:migration" json:"score,omitempty"` // fused rank score of hybrid search
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"-"`
 }
 
-func NewVectorDocument(docType DocumentType, content string, embedding []float32, metadata map[string]interface{}) *VectorDocument {
+func NewVectorDocument(positionID, kbVersionID uuid.UUID, docType DocumentType, content string, embedding []float32, metadata map[string]interface{}) *VectorDocument {
 	return &VectorDocument{
 		ID: uuid.New(),
+		PositionID: positionID,
+		KBVersionID: kbVersionID,
 		DocType: docType,
 		Content: content,
 		Embedding: pgvector.NewVector(embedding),
@@ -63,12 +83,37 @@ func NewVectorDocument(docType DocumentType, content string, embedding []float32
 	}
 }
 
+// filters applied by every search
+type SearchOptions struct {
+	PositionID uuid.UUID // limit to one position knowledge base, nil searches all
+	KBVersionID uuid.UUID // search this version instead of the active one
+	MinSimilarity float64 // drop chunks below this cosine similarity, 0 keeps all
+	Metadata MetadataFilter
+}
+
+// reciprocal rank fusion params, score = sum(weight / (k + rank)) over both rankings
+type HybridSearchOptions struct {
+	VectorWeight float64
+	LexicalWeight float64
+	K int
+	Candidates int
+}
+
 // contract
 type VectorRepository interface{
 	Create(ctx context.Context, doc *VectorDocument) error
-	SearchSimilar(ctx context.Context, embedding []float32, docType DocumentType, limit int) ([]*VectorDocument, error)
-	DeleteByDocType(ctx context.Context, docType DocumentType) error
-	Count(ctx context.Context, docType DocumentType) (int64, error)
+	CreateBatch(ctx context.Context, docs []*VectorDocument) error
+	SearchSimilar(ctx context.Context, embedding []float32, docType DocumentType, limit int, opts SearchOptions) ([]*VectorDocument, error)
+	SearchHybrid(ctx context.Context, embedding []float32, query string, docType DocumentType, limit int, opts SearchOptions, hybrid HybridSearchOptions) ([]*VectorDocument, error)
+	DeleteByVersion(ctx context.Context, kbVersionID uuid.UUID) error
+	FindByVersion(ctx context.Context, kbVersionID uuid.UUID) ([]*VectorDocument, error)
+	ListByVersion(ctx context.Context, kbVersionID uuid.UUID, limit, offset int) ([]*VectorDocument, int64, error)
+	Count(ctx context.Context, positionID uuid.UUID, docType DocumentType) (int64, error)
+	CountByPosition(ctx context.Context, positionID uuid.UUID) (map[DocumentType]int64, error)
+	EmbeddingDimension(ctx context.Context) (int, error)
+	FindWithoutEmbedding(ctx context.Context, limit int) ([]*VectorDocument, error)
+	// stores the embedding and the embedding_model metadata it was generated with
+	UpdateEmbedding(ctx context.Context, id uuid.UUID, embedding []float32, modelID string) error
 }
 
 func (VectorDocument) TableName() string {
@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/sawalreverr/cv-reviewer/pkg/response"
+)
+
+// protect admin routes with a static api key in the X-API-Key header,
+// an empty key disables the routes entirely
+func AdminAuth(apiKey string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if apiKey == "" {
+				return response.Error(c, http.StatusForbidden, "admin api is disabled", nil)
+			}
+
+			key := c.Request().Header.Get("X-API-Key")
+			if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+				return response.Error(c, http.StatusUnauthorized, "invalid api key", nil)
+			}
+
+			return next(c)
+		}
+	}
+}
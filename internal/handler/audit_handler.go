@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sawalreverr/cv-reviewer/internal/usecase"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+	"github.com/sawalreverr/cv-reviewer/pkg/response"
+)
+
+type AuditHandler struct {
+	usecase usecase.AuditUsecase
+}
+
+func NewAuditHandler(uc usecase.AuditUsecase) *AuditHandler {
+	return &AuditHandler{uc}
+}
+
+func (h *AuditHandler) GetJobLLMCalls(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid job id", err)
+	}
+
+	calls, err := h.usecase.GetJobLLMCalls(ctx, jobID)
+	if err != nil {
+		if err == errors.ErrJobNotFound {
+			return response.Error(c, http.StatusNotFound, "evaluation job not found", err)
+		}
+		return response.Error(c, http.StatusInternalServerError, "failed to get llm calls", err)
+	}
+
+	return response.SuccessData(c, calls)
+}
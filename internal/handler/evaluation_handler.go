@@ -2,6 +2,7 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
@@ -22,9 +23,12 @@ func NewEvaluationHandler(uc usecase.EvaluationUsecase, jobQueue service.JobQueu
 }
 
 type EvaluateRequest struct {
-	JobTitle string `json:"job_title" validate:"required"`
+	PositionID uuid.UUID `json:"position_id" validate:"required"`
+	JobTitle string `json:"job_title"` // defaults to the position title
 	CVID uuid.UUID `json:"cv_id" validate:"required"`
 	ProjectReportID uuid.UUID `json:"project_report_id" validate:"required"`
+	SkipCache bool `json:"skip_cache"` // force fresh llm calls
+	FeedbackLanguage string `json:"feedback_language"` // iso 639-1, defaults to en
 }
 
 type EvaluateResponse struct {
@@ -41,8 +45,8 @@ func (h *EvaluationHandler) Evaluate(c echo.Context) error {
 	}
 
 	// validate requeired field
-	if req.JobTitle == "" {
-		return response.Error(c, http.StatusBadRequest, "job_title is required", nil)
+	if req.PositionID == uuid.Nil {
+		return response.Error(c, http.StatusBadRequest, "position_id is required", nil)
 	}
 	if req.CVID == uuid.Nil {
 		return response.Error(c, http.StatusBadRequest, "cv_id is required", nil)
@@ -50,10 +54,19 @@ func (h *EvaluationHandler) Evaluate(c echo.Context) error {
 	if req.ProjectReportID == uuid.Nil {
 		return response.Error(c, http.StatusBadRequest, "project_report_id is required", nil)
 	}
+	if _, ok := domain.LanguageName(req.FeedbackLanguage); req.FeedbackLanguage != "" && !ok {
+		return response.Error(c, http.StatusBadRequest, "unsupported feedback_language", nil)
+	}
 
 	// create evaluation job
-	job, err := h.usecase.CreateEvaluationJob(ctx, req.JobTitle, req.CVID, req.ProjectReportID)
+	job, err := h.usecase.CreateEvaluationJob(ctx, req.PositionID, req.JobTitle, req.CVID, req.ProjectReportID, req.SkipCache, req.FeedbackLanguage)
 	if err != nil {
+		if err == errors.ErrPositionNotFound {
+			return response.Error(c, http.StatusNotFound, "position not found", err)
+		}
+		if err == errors.ErrKnowledgeBaseIncomplete {
+			return response.Error(c, http.StatusConflict, "position knowledge base is incomplete, ingest its job description, case study and rubrics first", err)
+		}
 		if err == errors.ErrNotFound {
 			return response.Error(c, http.StatusNotFound, "cv or project report document not found", err)
 		}
@@ -63,6 +76,7 @@ func (h *EvaluationHandler) Evaluate(c echo.Context) error {
 	// enqueue job for async processing
 	if err := h.jobQueue.Enqueue(service.Job{
 		ID: job.ID,
+		Kind: service.JobEvaluation,
 		JobTitle: job.JobTitle,
 		CVID: job.CVID,
 		ProjectID: job.ProjectReportID,
@@ -87,9 +101,31 @@ type ResultResponse struct {
 type ResultData struct {
 	CVMatchRate float64 `json:"cv_match_rate"`
 	CVFeedback string `json:"cv_feedback"`
+	CVCriteria []domain.CriterionScore `json:"cv_criteria"`
+	CVEvidence []domain.Evidence `json:"cv_evidence"`
 	ProjectScore float64 `json:"project_score"`
 	ProjectFeedback string `json:"project_feedback"`
+	ProjectCriteria []domain.CriterionScore `json:"project_criteria"`
+	ProjectEvidence []domain.Evidence `json:"project_evidence"`
 	OverallSummary string `json:"overall_summary"`
+	Recommendation domain.Recommendation `json:"recommendation"`
+	KeyStrengths []string `json:"key_strengths"`
+	KeyRisks []string `json:"key_risks"`
+	ScoreMismatch bool `json:"score_mismatch"`
+	SampleCount int `json:"sample_count"`
+	CVMatchRateStdDev float64 `json:"cv_match_rate_stddev"`
+	ProjectScoreStdDev float64 `json:"project_score_stddev"`
+	NeedsReview bool `json:"needs_review"`
+	PromptVersion string `json:"prompt_version"`
+	StageParams []domain.StageParams `json:"stage_params"`
+	InjectionRisk bool `json:"injection_risk"`
+	InjectionFindings []domain.InjectionFinding `json:"injection_findings,omitempty"`
+	FeedbackLanguage string `json:"feedback_language"`
+	CVLanguage string `json:"cv_language"`
+	ProjectLanguage string `json:"project_language"`
+	CVTextStrategy domain.TextStrategy `json:"cv_text_strategy"`
+	ProjectTextStrategy domain.TextStrategy `json:"project_text_strategy"`
+	KBVersions domain.KBVersionPins `json:"kb_versions"`
 }
 
 func (h *EvaluationHandler) GetResult(c echo.Context) error {
@@ -122,12 +158,115 @@ func (h *EvaluationHandler) GetResult(c echo.Context) error {
 		resp.Result = &ResultData{
 			CVMatchRate: result.CVMatchRate,
 			CVFeedback: result.CVFeedback,
+			CVCriteria: result.CVCriteria,
+			CVEvidence: result.CVEvidence,
 			ProjectScore: result.ProjectScore,
 			ProjectFeedback: result.ProjectFeedback,
+			ProjectCriteria: result.ProjectCriteria,
+			ProjectEvidence: result.ProjectEvidence,
 			OverallSummary: result.OverallSummary,
+			Recommendation: result.Recommendation,
+			KeyStrengths: result.KeyStrengths,
+			KeyRisks: result.KeyRisks,
+			ScoreMismatch: result.ScoreMismatch,
+			SampleCount: result.SampleCount,
+			CVMatchRateStdDev: result.CVMatchRateStdDev,
+			ProjectScoreStdDev: result.ProjectScoreStdDev,
+			NeedsReview: result.NeedsReview,
+			PromptVersion: result.PromptVersion,
+			StageParams: result.StageParams,
+			InjectionRisk: job.InjectionRisk,
+			InjectionFindings: job.InjectionFindings,
+			FeedbackLanguage: job.FeedbackLanguage,
+			CVLanguage: result.CVLanguage,
+			ProjectLanguage: result.ProjectLanguage,
+			CVTextStrategy: job.CVTextStrategy,
+			ProjectTextStrategy: job.ProjectTextStrategy,
+			KBVersions: job.KBVersions,
+		}
+	}
+
+	return response.SuccessData(c, resp)
+
+}
+
+type ResultListItem struct {
+	JobID uuid.UUID `json:"job_id"`
+	CVMatchRate float64 `json:"cv_match_rate"`
+	ProjectScore float64 `json:"project_score"`
+	Recommendation domain.Recommendation `json:"recommendation"`
+	KeyStrengths []string `json:"key_strengths"`
+	KeyRisks []string `json:"key_risks"`
+	NeedsReview bool `json:"needs_review"`
+}
+
+type ResultListResponse struct {
+	Items []ResultListItem `json:"items"`
+	Total int64 `json:"total"`
+}
+
+// query: recommendation, strength, risk, needs_review, limit, offset
+func (h *EvaluationHandler) ListResults(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	filter := domain.EvaluationResultFilter{
+		Strength: c.QueryParam("strength"),
+		Risk: c.QueryParam("risk"),
+	}
+
+	if recStr := c.QueryParam("recommendation"); recStr != "" {
+		rec, ok := domain.ParseRecommendation(recStr)
+		if !ok {
+			return response.Error(c, http.StatusBadRequest, "recommendation must be one of strong_hire, hire, maybe, pass", nil)
+		}
+		filter.Recommendation = rec
+	}
+
+	if reviewStr := c.QueryParam("needs_review"); reviewStr != "" {
+		needsReview, err := strconv.ParseBool(reviewStr)
+		if err != nil {
+			return response.Error(c, http.StatusBadRequest, "invalid needs_review, expected true or false", err)
+		}
+		filter.NeedsReview = &needsReview
+	}
+
+	var err error
+	if filter.Limit, err = intQueryParam(c, "limit"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid limit", err)
+	}
+	if filter.Offset, err = intQueryParam(c, "offset"); err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid offset", err)
+	}
+
+	results, total, err := h.usecase.ListResults(ctx, filter)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "failed to list evaluation results", err)
+	}
+
+	resp := ResultListResponse{
+		Items: make([]ResultListItem, len(results)),
+		Total: total,
+	}
+	for i, result := range results {
+		resp.Items[i] = ResultListItem{
+			JobID: result.JobID,
+			CVMatchRate: result.CVMatchRate,
+			ProjectScore: result.ProjectScore,
+			Recommendation: result.Recommendation,
+			KeyStrengths: result.KeyStrengths,
+			KeyRisks: result.KeyRisks,
+			NeedsReview: result.NeedsReview,
 		}
 	}
 
 	return response.SuccessData(c, resp)
+}
 
-}
\ No newline at end of file
+// missing query param is 0
+func intQueryParam(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -1,19 +1,55 @@
 package handler
 
 import (
+	"net/http"
+
 	"github.com/labstack/echo/v4"
+	"github.com/sawalreverr/cv-reviewer/internal/service"
 	"github.com/sawalreverr/cv-reviewer/pkg/response"
 )
 
-type HealthHandler struct{}
+type HealthHandler struct {
+	llmService service.LLMService
+}
 
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+type HealthResponse struct {
+	Status string `json:"status"`
+	Message string `json:"message"`
+	Models []service.BreakerStatus `json:"models"`
+}
+
+func NewHealthHandler(llmService service.LLMService) *HealthHandler {
+	return &HealthHandler{llmService}
 }
 
 func (h *HealthHandler) Check(c echo.Context) error {
-	return response.SuccessData(c, map[string]string{
-		"status": "ok",
-		"message": "api is running",
-	})
-}
\ No newline at end of file
+	models := h.llmService.ModelStatus()
+
+	// degraded while any model in the chain is tripped, down when none is usable
+	open := 0
+	for _, model := range models {
+		if model.State != service.BreakerClosed {
+			open++
+		}
+	}
+
+	status, message := "ok", "api is running"
+	switch {
+	case open == len(models):
+		status, message = "down", "all llm models are unavailable"
+	case open > 0:
+		status, message = "degraded", "some llm models are unavailable"
+	}
+
+	resp := HealthResponse{
+		Status: status,
+		Message: message,
+		Models: models,
+	}
+
+	// load balancers take the instance out while no model can serve
+	if status == "down" {
+		return c.JSON(http.StatusServiceUnavailable, response.Response{Success: false, Message: message, Data: resp})
+	}
+	return response.SuccessData(c, resp)
+}
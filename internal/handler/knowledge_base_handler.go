@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/internal/service"
+	"github.com/sawalreverr/cv-reviewer/internal/usecase"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+	"github.com/sawalreverr/cv-reviewer/pkg/response"
+)
+
+type KnowledgeBaseHandler struct {
+	usecase usecase.KnowledgeBaseUsecase
+	jobQueue service.JobQueue
+}
+
+func NewKnowledgeBaseHandler(uc usecase.KnowledgeBaseUsecase, jobQueue service.JobQueue) *KnowledgeBaseHandler {
+	return &KnowledgeBaseHandler{uc, jobQueue}
+}
+
+type KBUploadResponse struct {
+	VersionID uuid.UUID `json:"version_id"`
+	Version int `json:"version"`
+	Status string `json:"status"`
+}
+
+type ChunkPreview struct {
+	ID uuid.UUID `json:"id"`
+	Content string `json:"content"`
+	Metadata domain.JSONB `json:"metadata"`
+}
+
+type ChunkListResponse struct {
+	Items []ChunkPreview `json:"items"`
+	Total int64 `json:"total"`
+}
+
+// form: file (pdf), doc_type, metadata (optional json object copied into every chunk)
+func (h *KnowledgeBaseHandler) Upload(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	positionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid position id", err)
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "file required", err)
+	}
+
+	docType := domain.DocumentType(c.FormValue("doc_type"))
+	if !domain.IsKnowledgeBaseType(docType) {
+		return response.Error(c, http.StatusBadRequest, "doc_type must be one of job_description, case_study_brief, cv_rubric, project_rubric", nil)
+	}
+
+	var metadata map[string]interface{}
+	if raw := c.FormValue("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			return response.Error(c, http.StatusBadRequest, "metadata must be a json object", err)
+		}
+	}
+
+	version, err := h.usecase.UploadDocument(ctx, positionID, file, docType, metadata)
+	if err != nil {
+		switch err {
+		case errors.ErrPositionNotFound:
+			return response.Error(c, http.StatusNotFound, "position not found", err)
+		case errors.ErrInvalidType:
+			return response.Error(c, http.StatusBadRequest, "invalid file type, only pdf allowed", err)
+		default:
+			return response.Error(c, http.StatusInternalServerError, "failed to upload knowledge base document", err)
+		}
+	}
+
+	// ingest async, the current version stays live until the new one is swapped in
+	if err := h.jobQueue.Enqueue(service.Job{ID: version.ID, Kind: service.JobIngest}); err != nil {
+		h.usecase.FailIngest(ctx, version.ID, err)
+		return response.Error(c, http.StatusServiceUnavailable, "job queue is full, please try again later", err)
+	}
+
+	resp := KBUploadResponse{
+		VersionID: version.ID,
+		Version: version.Version,
+		Status: string(version.Status),
+	}
+
+	return response.Success(c, http.StatusAccepted, "knowledge base ingestion queued", resp)
+}
+
+func (h *KnowledgeBaseHandler) Get(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	positionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid position id", err)
+	}
+
+	kb, err := h.usecase.GetKnowledgeBase(ctx, positionID)
+	if err != nil {
+		if err == errors.ErrPositionNotFound {
+			return response.Error(c, http.StatusNotFound, "position not found", err)
+		}
+		return response.Error(c, http.StatusInternalServerError, "failed to get knowledge base", err)
+	}
+
+	return response.SuccessData(c, kb)
+}
+
+func (h *KnowledgeBaseHandler) GetVersion(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	versionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid version id", err)
+	}
+
+	version, err := h.usecase.GetVersion(ctx, versionID)
+	if err != nil {
+		return h.handleVersionError(c, err, "failed to get knowledge base version")
+	}
+
+	return response.SuccessData(c, version)
+}
+
+// query: limit, offset
+func (h *KnowledgeBaseHandler) ListChunks(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	versionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid version id", err)
+	}
+
+	limit, err := intQueryParam(c, "limit")
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid limit", err)
+	}
+	offset, err := intQueryParam(c, "offset")
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid offset", err)
+	}
+
+	chunks, total, err := h.usecase.ListChunks(ctx, versionID, limit, offset)
+	if err != nil {
+		return h.handleVersionError(c, err, "failed to list chunks")
+	}
+
+	resp := ChunkListResponse{
+		Items: make([]ChunkPreview, len(chunks)),
+		Total: total,
+	}
+	for i, chunk := range chunks {
+		resp.Items[i] = ChunkPreview{
+			ID: chunk.ID,
+			Content: chunk.Content,
+			Metadata: chunk.Metadata,
+		}
+	}
+
+	return response.SuccessData(c, resp)
+}
+
+// swap a version back in, e.g. to roll back a rubric update
+func (h *KnowledgeBaseHandler) Activate(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	versionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid version id", err)
+	}
+
+	version, err := h.usecase.ActivateVersion(ctx, versionID)
+	if err != nil {
+		return h.handleVersionError(c, err, "failed to activate knowledge base version")
+	}
+
+	return response.Success(c, http.StatusOK, "knowledge base version activated", version)
+}
+
+func (h *KnowledgeBaseHandler) Delete(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	versionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid version id", err)
+	}
+
+	if err := h.usecase.DeleteVersion(ctx, versionID); err != nil {
+		return h.handleVersionError(c, err, "failed to delete knowledge base version")
+	}
+
+	return response.Success(c, http.StatusOK, "knowledge base version deleted", nil)
+}
+
+func (h *KnowledgeBaseHandler) handleVersionError(c echo.Context, err error, msg string) error {
+	switch err {
+	case errors.ErrKBVersionNotFound:
+		return response.Error(c, http.StatusNotFound, "knowledge base version not found", err)
+	case errors.ErrKBVersionNotReady:
+		return response.Error(c, http.StatusConflict, "only ingested versions can be activated", err)
+	case errors.ErrKBVersionInUse:
+		return response.Error(c, http.StatusConflict, "active, ingesting or versions pinned by a running evaluation cannot be deleted", err)
+	default:
+		return response.Error(c, http.StatusInternalServerError, msg, err)
+	}
+}
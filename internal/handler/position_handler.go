@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sawalreverr/cv-reviewer/internal/usecase"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+	"github.com/sawalreverr/cv-reviewer/pkg/response"
+)
+
+type PositionHandler struct {
+	usecase usecase.PositionUsecase
+}
+
+func NewPositionHandler(uc usecase.PositionUsecase) *PositionHandler {
+	return &PositionHandler{uc}
+}
+
+type CreatePositionRequest struct {
+	Slug string `json:"slug" validate:"required"`
+	Title string `json:"title" validate:"required"`
+	Description string `json:"description"`
+}
+
+func (h *PositionHandler) Create(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req CreatePositionRequest
+	if err := c.Bind(&req); err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid request body", err)
+	}
+
+	// validate required field
+	if req.Slug == "" {
+		return response.Error(c, http.StatusBadRequest, "slug is required", nil)
+	}
+	if req.Title == "" {
+		return response.Error(c, http.StatusBadRequest, "title is required", nil)
+	}
+
+	position, err := h.usecase.CreatePosition(ctx, req.Slug, req.Title, req.Description)
+	if err != nil {
+		if err == errors.ErrInvalidInput {
+			return response.Error(c, http.StatusBadRequest, "slug must be lowercase letters, digits and dashes", err)
+		}
+		if err == errors.ErrPositionExists {
+			return response.Error(c, http.StatusConflict, "position slug already exists", err)
+		}
+		return response.Error(c, http.StatusInternalServerError, "failed to create position", err)
+	}
+
+	return response.Success(c, http.StatusCreated, "position created", position)
+}
+
+func (h *PositionHandler) Get(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid position id", err)
+	}
+
+	position, err := h.usecase.GetPosition(ctx, id)
+	if err != nil {
+		if err == errors.ErrPositionNotFound {
+			return response.Error(c, http.StatusNotFound, "position not found", err)
+		}
+		return response.Error(c, http.StatusInternalServerError, "failed to get position", err)
+	}
+
+	return response.SuccessData(c, position)
+}
+
+func (h *PositionHandler) List(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	positions, err := h.usecase.ListPositions(ctx)
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "failed to list positions", err)
+	}
+
+	return response.SuccessData(c, positions)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/sawalreverr/cv-reviewer/internal/usecase"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+	"github.com/sawalreverr/cv-reviewer/pkg/response"
+)
+
+const dateLayout = "2006-01-02"
+
+type UsageHandler struct {
+	usecase usecase.UsageUsecase
+}
+
+func NewUsageHandler(uc usecase.UsageUsecase) *UsageHandler {
+	return &UsageHandler{uc}
+}
+
+func (h *UsageHandler) GetJobUsage(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return response.Error(c, http.StatusBadRequest, "invalid job id", err)
+	}
+
+	usage, err := h.usecase.GetJobUsage(ctx, jobID)
+	if err != nil {
+		if err == errors.ErrJobNotFound {
+			return response.Error(c, http.StatusNotFound, "evaluation job not found", err)
+		}
+		return response.Error(c, http.StatusInternalServerError, "failed to get job usage", err)
+	}
+
+	return response.SuccessData(c, usage)
+}
+
+// query: from, to (YYYY-MM-DD, inclusive), defaults to the last 30 days
+func (h *UsageHandler) GetDailyUsage(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	from := today.AddDate(0, 0, -29)
+	to := today
+
+	if fromStr := c.QueryParam("from"); fromStr != "" {
+		parsed, err := time.Parse(dateLayout, fromStr)
+		if err != nil {
+			return response.Error(c, http.StatusBadRequest, "invalid from date, expected YYYY-MM-DD", err)
+		}
+		from = parsed
+	}
+
+	if toStr := c.QueryParam("to"); toStr != "" {
+		parsed, err := time.Parse(dateLayout, toStr)
+		if err != nil {
+			return response.Error(c, http.StatusBadRequest, "invalid to date, expected YYYY-MM-DD", err)
+		}
+		to = parsed
+	}
+
+	if to.Before(from) {
+		return response.Error(c, http.StatusBadRequest, "to date must not be before from date", nil)
+	}
+
+	usage, err := h.usecase.GetDailyUsage(ctx, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return response.Error(c, http.StatusInternalServerError, "failed to get daily usage", err)
+	}
+
+	return response.SuccessData(c, usage)
+}
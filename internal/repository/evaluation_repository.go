@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/sawalreverr/cv-reviewer/internal/domain"
@@ -13,7 +14,7 @@ import (
 // evaluation job
 type evaluationJobRepository struct {
 	db *gorm.DB
-} 
+}
 
 func NewEvaluationJobRepository(db *gorm.DB) domain.EvaluationJobRepository {
 	return &evaluationJobRepository{db}
@@ -35,7 +36,7 @@ func (r *evaluationJobRepository) FindByID(ctx context.Context, id uuid.UUID) (*
 		}
 
 		return nil, fmt.Errorf("failed to find evaluation job: %w", err)
-	}	
+	}
 
 	return &job, nil
 }
@@ -85,4 +86,39 @@ func (r *evaluationResultRepository) FindByJobID(ctx context.Context, jobID uuid
 	}
 
 	return &result, nil
-} 
\ No newline at end of file
+}
+
+func (r *evaluationResultRepository) List(ctx context.Context, filter domain.EvaluationResultFilter) ([]*domain.EvaluationResult, int64, error) {
+	query := r.db.WithContext(ctx).Model(&domain.EvaluationResult{})
+
+	if filter.Recommendation != "" {
+		query = query.Where("recommendation = ?", filter.Recommendation)
+	}
+	if filter.Strength != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM jsonb_array_elements_text(key_strengths) AS s WHERE s ILIKE ?)", likePattern(filter.Strength))
+	}
+	if filter.Risk != "" {
+		query = query.Where("EXISTS (SELECT 1 FROM jsonb_array_elements_text(key_risks) AS r WHERE r ILIKE ?)", likePattern(filter.Risk))
+	}
+	if filter.NeedsReview != nil {
+		query = query.Where("needs_review = ?", *filter.NeedsReview)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count evaluation results: %w", err)
+	}
+
+	var results []*domain.EvaluationResult
+	if err := query.Order("created_at DESC").Limit(filter.Limit).Offset(filter.Offset).Find(&results).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list evaluation results: %w", err)
+	}
+
+	return results, total, nil
+}
+
+// substring ILIKE pattern with wildcards in the input escaped
+func likePattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return "%" + escaped + "%"
+}
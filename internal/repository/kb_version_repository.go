@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type kbVersionRepository struct {
+	db *gorm.DB
+}
+
+func NewKBVersionRepository(db *gorm.DB) domain.KBVersionRepository {
+	return &kbVersionRepository{db}
+}
+
+func (r *kbVersionRepository) CreateStaged(ctx context.Context, version *domain.KBVersion) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// concurrent ingests of the same document pick the same number, the unique index rejects the second
+		var latest int
+		if err := tx.Model(&domain.KBVersion{}).Select("COALESCE(MAX(version), 0)").Where("position_id = ? AND doc_type = ?", version.PositionID, version.DocType).Scan(&latest).Error; err != nil {
+			return err
+		}
+
+		version.Version = latest + 1
+		version.Status = domain.KBStaging
+		return tx.Create(version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create knowledge base version: %w", err)
+	}
+
+	return nil
+}
+
+func (r *kbVersionRepository) Activate(ctx context.Context, id uuid.UUID) (*domain.KBVersion, error) {
+	var version domain.KBVersion
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", id).First(&version).Error; err != nil {
+			return err
+		}
+
+		// lock every version of the document so concurrent swaps are serialized
+		var siblings []*domain.KBVersion
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("position_id = ? AND doc_type = ?", version.PositionID, version.DocType).Find(&siblings).Error; err != nil {
+			return err
+		}
+		for _, sibling := range siblings {
+			if sibling.ID == id {
+				version = *sibling
+			}
+		}
+
+		if version.Status == domain.KBActive {
+			return nil
+		}
+		if version.Status == domain.KBFailed || version.ChunkCount == 0 {
+			return errors.ErrKBVersionNotReady
+		}
+
+		if err := tx.Model(&domain.KBVersion{}).Where("position_id = ? AND doc_type = ? AND status = ?", version.PositionID, version.DocType, domain.KBActive).Update("status", domain.KBArchived).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		version.Status = domain.KBActive
+		version.ActivatedAt = &now
+		return tx.Save(&version).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrKBVersionNotFound
+		}
+		if err == errors.ErrKBVersionNotReady {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to activate knowledge base version: %w", err)
+	}
+
+	return &version, nil
+}
+
+func (r *kbVersionRepository) Update(ctx context.Context, version *domain.KBVersion) error {
+	if err := r.db.WithContext(ctx).Save(version).Error; err != nil {
+		return fmt.Errorf("failed to update knowledge base version: %w", err)
+	}
+
+	return nil
+}
+
+func (r *kbVersionRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.KBVersion, error) {
+	var version domain.KBVersion
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&version).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrKBVersionNotFound
+		}
+
+		return nil, fmt.Errorf("failed to find knowledge base version: %w", err)
+	}
+
+	return &version, nil
+}
+
+func (r *kbVersionRepository) FindActive(ctx context.Context, positionID uuid.UUID) ([]*domain.KBVersion, error) {
+	var versions []*domain.KBVersion
+	if err := r.db.WithContext(ctx).Where("position_id = ? AND status = ?", positionID, domain.KBActive).Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to find active knowledge base versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+func (r *kbVersionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Where("id = ?", id).Delete(&domain.KBVersion{}).Error; err != nil {
+		return fmt.Errorf("failed to delete knowledge base version: %w", err)
+	}
+
+	return nil
+}
+
+// newest first per document type
+func (r *kbVersionRepository) ListByPosition(ctx context.Context, positionID uuid.UUID) ([]*domain.KBVersion, error) {
+	var versions []*domain.KBVersion
+	if err := r.db.WithContext(ctx).Where("position_id = ?", positionID).Order("doc_type, version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list knowledge base versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+func (r *kbVersionRepository) Claim(ctx context.Context, version *domain.KBVersion) (bool, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&domain.KBVersion{}).
+		Where("id = ? AND status = ? AND started_at IS NULL", version.ID, domain.KBStaging).
+		Updates(map[string]interface{}{"started_at": now, "heartbeat_at": now})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim knowledge base version: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	version.StartedAt = &now
+	version.HeartbeatAt = &now
+	return true, nil
+}
+
+func (r *kbVersionRepository) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Model(&domain.KBVersion{}).Where("id = ? AND status = ?", id, domain.KBStaging).Update("heartbeat_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to refresh knowledge base version heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+func (r *kbVersionRepository) ListStale(ctx context.Context, before time.Time) ([]*domain.KBVersion, error) {
+	var versions []*domain.KBVersion
+	if err := r.db.WithContext(ctx).Where("status = ? AND ((started_at IS NULL AND created_at < ?) OR heartbeat_at < ?)", domain.KBStaging, before, before).Order("created_at").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list stale knowledge base versions: %w", err)
+	}
+
+	return versions, nil
+}
+
+func (r *kbVersionRepository) IsPinned(ctx context.Context, version *domain.KBVersion) (bool, error) {
+	pin := domain.KBVersionPins{version.DocType: version.ID}
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&domain.EvaluationJob{}).Where("status IN ? AND kb_versions @> ?", []domain.JobStatus{domain.StatusQueued, domain.StatusProcessing}, pin).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check knowledge base version pins: %w", err)
+	}
+
+	return count > 0, nil
+}
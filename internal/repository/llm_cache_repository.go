@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type llmCacheRepository struct {
+	db *gorm.DB
+}
+
+func NewLLMCacheRepository(db *gorm.DB) domain.LLMCacheRepository {
+	return &llmCacheRepository{db}
+}
+
+// expired entries are treated as missing
+func (r *llmCacheRepository) Get(ctx context.Context, key string) (*domain.LLMCacheEntry, error) {
+	var entry domain.LLMCacheEntry
+	if err := r.db.WithContext(ctx).Where("key = ? AND expires_at > ?", key, time.Now()).First(&entry).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get llm cache entry: %w", err)
+	}
+
+	return &entry, nil
+}
+
+func (r *llmCacheRepository) Set(ctx context.Context, entry *domain.LLMCacheEntry) error {
+	query := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"model", "response", "expires_at", "created_at"}),
+	})
+
+	if err := query.Create(entry).Error; err != nil {
+		return fmt.Errorf("failed to set llm cache entry: %w", err)
+	}
+
+	return nil
+}
+
+func (r *llmCacheRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", time.Now()).Delete(&domain.LLMCacheEntry{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired llm cache entries: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"gorm.io/gorm"
+)
+
+type llmCallRepository struct {
+	db *gorm.DB
+}
+
+func NewLLMCallRepository(db *gorm.DB) domain.LLMCallRepository {
+	return &llmCallRepository{db}
+}
+
+func (r *llmCallRepository) CreateBatch(ctx context.Context, calls []*domain.LLMCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	if err := r.db.WithContext(ctx).Create(&calls).Error; err != nil {
+		return fmt.Errorf("failed to create llm calls: %w", err)
+	}
+
+	return nil
+}
+
+func (r *llmCallRepository) FindByJobID(ctx context.Context, jobID uuid.UUID) ([]*domain.LLMCall, error) {
+	var calls []*domain.LLMCall
+	if err := r.db.WithContext(ctx).Where("job_id = ?", jobID).Order("created_at ASC").Find(&calls).Error; err != nil {
+		return nil, fmt.Errorf("failed to find llm calls: %w", err)
+	}
+
+	return calls, nil
+}
+
+// drop prompt and response text of old calls, usage figures are kept for accounting
+func (r *llmCallRepository) PurgeContentBefore(ctx context.Context, before time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&domain.LLMCall{}).
+		Where("created_at < ? AND (prompt IS NOT NULL OR response IS NOT NULL)", before).
+		Updates(map[string]interface{}{"prompt": nil, "response": nil})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge llm call content: %w", result.Error)
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (r *llmCallRepository) SumByJob(ctx context.Context, jobID uuid.UUID) ([]*domain.UsageSummary, error) {
+	var summaries []*domain.UsageSummary
+
+	query := r.db.WithContext(ctx).Model(&domain.LLMCall{}).
+		Select("stage, COUNT(*) as calls, COUNT(*) FILTER (WHERE cached) as cached_calls, SUM(input_tokens) as input_tokens, SUM(output_tokens) as output_tokens, SUM(cost) as cost").
+		Where("job_id = ?", jobID).
+		Group("stage").
+		Order("stage")
+
+	if err := query.Scan(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum llm usage by job: %w", err)
+	}
+
+	return summaries, nil
+}
+
+// days are utc like from and to, whatever the session timezone
+func (r *llmCallRepository) SumByDay(ctx context.Context, from, to time.Time) ([]*domain.UsageSummary, error) {
+	var summaries []*domain.UsageSummary
+
+	query := r.db.WithContext(ctx).Model(&domain.LLMCall{}).
+		Select("to_char(created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') as day, COUNT(DISTINCT job_id) as jobs, COUNT(*) as calls, COUNT(*) FILTER (WHERE cached) as cached_calls, SUM(input_tokens) as input_tokens, SUM(output_tokens) as output_tokens, SUM(cost) as cost").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("day").
+		Order("day")
+
+	if err := query.Scan(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum llm usage by day: %w", err)
+	}
+
+	return summaries, nil
+}
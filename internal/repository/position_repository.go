@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+	"gorm.io/gorm"
+)
+
+type positionRepository struct {
+	db *gorm.DB
+}
+
+func NewPositionRepository(db *gorm.DB) domain.PositionRepository {
+	return &positionRepository{db}
+}
+
+func (r *positionRepository) Create(ctx context.Context, position *domain.Position) error {
+	if err := r.db.WithContext(ctx).Create(position).Error; err != nil {
+		// a concurrent create with the same slug passed the existence check too
+		if isUniqueViolation(err) {
+			return errors.ErrPositionExists
+		}
+		return fmt.Errorf("failed to create position: %w", err)
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return stderrors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func (r *positionRepository) FindByID(ctx context.Context, id uuid.UUID) (*domain.Position, error) {
+	return r.findOne(ctx, "id = ?", id)
+}
+
+func (r *positionRepository) FindBySlug(ctx context.Context, slug string) (*domain.Position, error) {
+	return r.findOne(ctx, "slug = ?", slug)
+}
+
+func (r *positionRepository) List(ctx context.Context) ([]*domain.Position, error) {
+	var positions []*domain.Position
+	if err := r.db.WithContext(ctx).Order("slug").Find(&positions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list positions: %w", err)
+	}
+
+	return positions, nil
+}
+
+func (r *positionRepository) findOne(ctx context.Context, query string, arg interface{}) (*domain.Position, error) {
+	var position domain.Position
+	if err := r.db.WithContext(ctx).Where(query, arg).First(&position).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errors.ErrPositionNotFound
+		}
+
+		return nil, fmt.Errorf("failed to find position: %w", err)
+	}
+
+	return &position, nil
+}
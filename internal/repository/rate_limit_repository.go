@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type rateLimitRepository struct {
+	db *gorm.DB
+}
+
+func NewRateLimitRepository(db *gorm.DB) domain.RateLimitRepository {
+	return &rateLimitRepository{db}
+}
+
+func (r *rateLimitRepository) WithLock(ctx context.Context, name string, fn func(bucket *domain.RateLimitBucket) error) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// new buckets start empty with a zero refill time, the limiter fills them on first use
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&domain.RateLimitBucket{Name: name}).Error; err != nil {
+			return err
+		}
+
+		var bucket domain.RateLimitBucket
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", name).First(&bucket).Error; err != nil {
+			return err
+		}
+
+		if err := fn(&bucket); err != nil {
+			return err
+		}
+
+		return tx.Save(&bucket).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update rate limit bucket: %w", err)
+	}
+
+	return nil
+}
@@ -2,11 +2,16 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/google/uuid"
 	"github.com/pgvector/pgvector-go"
 	"github.com/sawalreverr/cv-reviewer/internal/domain"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type vectorRepository struct {
@@ -25,13 +30,32 @@ func (r *vectorRepository) Create(ctx context.Context, doc *domain.VectorDocumen
 	return nil
 }
 
-func (r *vectorRepository) SearchSimilar(ctx context.Context, embedding []float32, docType domain.DocumentType, limit int) ([]*domain.VectorDocument, error) {
+// all chunks or none, a half written version is never left behind
+func (r *vectorRepository) CreateBatch(ctx context.Context, docs []*domain.VectorDocument) error {
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.CreateInBatches(docs, 100).Error
+	}); err != nil {
+		return fmt.Errorf("failed to create vector documents: %w", err)
+	}
+
+	return nil
+}
+
+func (r *vectorRepository) SearchSimilar(ctx context.Context, embedding []float32, docType domain.DocumentType, limit int, opts domain.SearchOptions) ([]*domain.VectorDocument, error) {
 	var docs []*domain.VectorDocument
 
+	if err := validateMetadataFilter(opts.Metadata); err != nil {
+		return nil, err
+	}
+
 	pgEmbedding := pgvector.NewVector(embedding)
 
 	// cosine similarity (skor similar 0-1), order by distance closest to farthest
 	query := r.db.WithContext(ctx).Select("*, 1 - (embedding <=> ?) as similarity", pgEmbedding).Where("doc_type = ?", docType).Order(gorm.Expr("embedding <=> ?", pgEmbedding)).Limit(limit)
+	if opts.MinSimilarity > 0 {
+		query = query.Where("1 - (embedding <=> ?) >= ?", pgEmbedding, opts.MinSimilarity)
+	}
+	query = query.Where(searchFilterExpr(opts))
 
 	if err := query.Find(&docs).Error; err != nil {
 		return nil, fmt.Errorf("failed to search similar documents: %w", err)
@@ -40,18 +64,263 @@ func (r *vectorRepository) SearchSimilar(ctx context.Context, embedding []float3
 	return docs, nil
 }
 
-func (r *vectorRepository) DeleteByDocType(ctx context.Context, docType domain.DocumentType) error {
-	if err := r.db.WithContext(ctx).Where("doc_type = ?", docType).Delete(&domain.VectorDocument{}).Error; err != nil {
+// rank by cosine distance and by full-text rank separately, then fuse both rankings (rrf).
+// lexical terms are or-ed so a long query still matches chunks sharing a few keywords
+func (r *vectorRepository) SearchHybrid(ctx context.Context, embedding []float32, query string, docType domain.DocumentType, limit int, opts domain.SearchOptions, hybrid domain.HybridSearchOptions) ([]*domain.VectorDocument, error) {
+	var docs []*domain.VectorDocument
+
+	if err := validateMetadataFilter(opts.Metadata); err != nil {
+		return nil, err
+	}
+
+	sql := `
+		WITH vector_rank AS (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY embedding <=> @embedding) AS rank
+			FROM vector_documents
+			WHERE doc_type = @doc_type AND @filter
+			ORDER BY embedding <=> @embedding
+			LIMIT @candidates
+		), lexical_rank AS (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY ts_rank_cd(content_tsv, tsq.q) DESC) AS rank
+			FROM vector_documents
+			CROSS JOIN LATERAL (
+				SELECT NULLIF(replace(plainto_tsquery('simple', @query)::text, '&', '|'), '')::tsquery AS q
+			) tsq
+			WHERE doc_type = @doc_type AND @filter AND content_tsv @@ tsq.q
+			ORDER BY ts_rank_cd(content_tsv, tsq.q) DESC
+			LIMIT @candidates
+		)
+		SELECT d.*,
+			1 - (d.embedding <=> @embedding) AS similarity,
+			COALESCE(CAST(@vector_weight AS float8) / (CAST(@k AS int) + v.rank), 0) +
+			COALESCE(CAST(@lexical_weight AS float8) / (CAST(@k AS int) + l.rank), 0) AS score
+		FROM vector_documents d
+		LEFT JOIN vector_rank v ON v.id = d.id
+		LEFT JOIN lexical_rank l ON l.id = d.id
+		WHERE (v.id IS NOT NULL OR l.id IS NOT NULL)
+		AND (CAST(@min_similarity AS float8) <= 0 OR 1 - (d.embedding <=> @embedding) >= CAST(@min_similarity AS float8))
+		ORDER BY score DESC
+		LIMIT @limit
+	`
+
+	params := map[string]interface{}{
+		"embedding": pgvector.NewVector(embedding),
+		"query": query,
+		"doc_type": docType,
+		"candidates": hybrid.Candidates,
+		"vector_weight": hybrid.VectorWeight,
+		"lexical_weight": hybrid.LexicalWeight,
+		"k": hybrid.K,
+		"min_similarity": opts.MinSimilarity,
+		"filter": searchFilterExpr(opts),
+		"limit": limit,
+	}
+
+	if err := r.db.WithContext(ctx).Raw(sql, params).Scan(&docs).Error; err != nil {
+		return nil, fmt.Errorf("failed to hybrid search documents: %w", err)
+	}
+
+	return docs, nil
+}
+
+// knowledge base version, position and metadata conditions of a search as one expression.
+// without a pinned version only chunks of active versions are searched, staging chunks stay invisible
+func searchFilterExpr(opts domain.SearchOptions) clause.Expr {
+	if opts.KBVersionID != uuid.Nil {
+		return gorm.Expr("kb_version_id = ? AND ?", opts.KBVersionID, metadataFilterExpr(opts.Metadata))
+	}
+	if opts.PositionID == uuid.Nil {
+		return gorm.Expr("kb_version_id IN (SELECT id FROM kb_versions WHERE status = ?) AND ?", domain.KBActive, metadataFilterExpr(opts.Metadata))
+	}
+
+	return gorm.Expr("kb_version_id IN (SELECT id FROM kb_versions WHERE position_id = ? AND status = ?) AND ?", opts.PositionID, domain.KBActive, metadataFilterExpr(opts.Metadata))
+}
+
+// sql of the range operators, anything else never reaches the query text
+var metadataRangeOps = map[domain.MetadataOp]string{
+	domain.MetadataGt: ">",
+	domain.MetadataGte: ">=",
+	domain.MetadataLt: "<",
+	domain.MetadataLte: "<=",
+}
+
+// filters can come from json as well as from ParseMetadataFilter, so they are checked again here
+func validateMetadataFilter(filter domain.MetadataFilter) error {
+	for _, condition := range filter {
+		if len(condition.Values) == 0 {
+			return fmt.Errorf("invalid metadata filter: no value for key %q", condition.Key)
+		}
+
+		switch condition.Op {
+		case domain.MetadataEq, domain.MetadataIn:
+		default:
+			if _, ok := metadataRangeOps[condition.Op]; !ok {
+				return fmt.Errorf("invalid metadata filter: unknown operator %q on key %q", condition.Op, condition.Key)
+			}
+			if len(condition.Values) != 1 {
+				return fmt.Errorf("invalid metadata filter: range on key %q needs exactly one value", condition.Key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// equality and IN use jsonb containment so the gin (jsonb_path_ops) index applies,
+// a value that parses as number or bool also matches its typed json form.
+// ranges compare numeric values only, non numeric values never match
+func metadataFilterExpr(filter domain.MetadataFilter) clause.Expr {
+	if len(filter) == 0 {
+		return gorm.Expr("TRUE")
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, condition := range filter {
+		// searches reject invalid filters up front, anything else matches nothing rather than build broken sql
+		if validateMetadataFilter(domain.MetadataFilter{condition}) != nil {
+			conditions = append(conditions, "FALSE")
+			continue
+		}
+
+		switch condition.Op {
+		case domain.MetadataEq, domain.MetadataIn:
+			var matches []string
+			for _, value := range condition.Values {
+				for _, candidate := range jsonCandidates(value) {
+					doc, _ := json.Marshal(map[string]interface{}{condition.Key: candidate})
+					matches = append(matches, "metadata @> ?::jsonb")
+					args = append(args, string(doc))
+				}
+			}
+			conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+		default:
+			// case keeps the cast from running on non numeric values
+			conditions = append(conditions, fmt.Sprintf("(CASE WHEN jsonb_typeof(metadata -> ?) = 'number' THEN (metadata ->> ?)::numeric END %s ?)", metadataRangeOps[condition.Op]))
+			args = append(args, condition.Key, condition.Key, condition.Values[0])
+		}
+	}
+
+	return gorm.Expr(strings.Join(conditions, " AND "), args...)
+}
+
+// json forms a filter value may be stored as
+func jsonCandidates(value interface{}) []interface{} {
+	text, ok := value.(string)
+	if !ok {
+		return []interface{}{value}
+	}
+
+	candidates := []interface{}{text}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		candidates = append(candidates, number)
+	}
+	if b, err := strconv.ParseBool(text); err == nil {
+		candidates = append(candidates, b)
+	}
+	return candidates
+}
+
+func (r *vectorRepository) DeleteByVersion(ctx context.Context, kbVersionID uuid.UUID) error {
+	if err := r.db.WithContext(ctx).Where("kb_version_id = ?", kbVersionID).Delete(&domain.VectorDocument{}).Error; err != nil {
 		return fmt.Errorf("failed to delete vector documents: %w", err)
 	}
 	return nil
 }
 
-func (r *vectorRepository) Count(ctx context.Context, docType domain.DocumentType) (int64, error) {
+// embedded chunks of a version in document order, chunks waiting for re-embedding are left out
+func (r *vectorRepository) FindByVersion(ctx context.Context, kbVersionID uuid.UUID) ([]*domain.VectorDocument, error) {
+	var docs []*domain.VectorDocument
+	if err := r.db.WithContext(ctx).Where("kb_version_id = ? AND embedding IS NOT NULL", kbVersionID).Order("(metadata ->> 'chunk_index')::int, created_at").Find(&docs).Error; err != nil {
+		return nil, fmt.Errorf("failed to find vector documents: %w", err)
+	}
+
+	return docs, nil
+}
+
+// page of chunks of a version for preview, embeddings are not loaded
+func (r *vectorRepository) ListByVersion(ctx context.Context, kbVersionID uuid.UUID, limit, offset int) ([]*domain.VectorDocument, int64, error) {
+	var docs []*domain.VectorDocument
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&domain.VectorDocument{}).Where("kb_version_id = ?", kbVersionID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count vector documents: %w", err)
+	}
+
+	if err := query.Select("id, position_id, kb_version_id, doc_type, content, metadata, created_at").Order("(metadata ->> 'chunk_index')::int, created_at").Limit(limit).Offset(offset).Find(&docs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list vector documents: %w", err)
+	}
+
+	return docs, total, nil
+}
+
+// chunks of the active version
+func (r *vectorRepository) Count(ctx context.Context, positionID uuid.UUID, docType domain.DocumentType) (int64, error) {
 	var count int64
-	if err := r.db.WithContext(ctx).Model(&domain.VectorDocument{}).Where("doc_type = ?", docType).Count(&count).Error; err != nil {
+	if err := r.db.WithContext(ctx).Model(&domain.VectorDocument{}).Where("doc_type = ?", docType).Where(searchFilterExpr(domain.SearchOptions{PositionID: positionID})).Count(&count).Error; err != nil {
 		return 0, fmt.Errorf("failed to count vector documents: %w", err)
 	}
 
 	return count, nil
-}
\ No newline at end of file
+}
+
+// chunk count per document type of the active versions of one position
+func (r *vectorRepository) CountByPosition(ctx context.Context, positionID uuid.UUID) (map[domain.DocumentType]int64, error) {
+	var rows []struct {
+		DocType domain.DocumentType
+		Count int64
+	}
+
+	query := r.db.WithContext(ctx).Model(&domain.VectorDocument{}).Select("doc_type, COUNT(*) as count").Where(searchFilterExpr(domain.SearchOptions{PositionID: positionID})).Group("doc_type")
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to count vector documents: %w", err)
+	}
+
+	counts := make(map[domain.DocumentType]int64, len(rows))
+	for _, row := range rows {
+		counts[row.DocType] = row.Count
+	}
+
+	return counts, nil
+}
+
+// dimension of the live embedding column, read from the column type modifier
+func (r *vectorRepository) EmbeddingDimension(ctx context.Context) (int, error) {
+	var dimension int
+	query := `
+		SELECT atttypmod
+		FROM pg_attribute
+		WHERE attrelid = 'vector_documents'::regclass
+		AND attname = 'embedding'
+		AND NOT attisdropped
+	`
+	if err := r.db.WithContext(ctx).Raw(query).Scan(&dimension).Error; err != nil {
+		return 0, fmt.Errorf("failed to read embedding column dimension: %w", err)
+	}
+
+	return dimension, nil
+}
+
+// documents left without an embedding after the column was rebuilt, embedding itself is not loaded
+func (r *vectorRepository) FindWithoutEmbedding(ctx context.Context, limit int) ([]*domain.VectorDocument, error) {
+	var docs []*domain.VectorDocument
+	query := r.db.WithContext(ctx).Select("id, doc_type, content, metadata, created_at").Where("embedding IS NULL").Order("created_at").Limit(limit)
+	if err := query.Find(&docs).Error; err != nil {
+		return nil, fmt.Errorf("failed to find documents without embedding: %w", err)
+	}
+
+	return docs, nil
+}
+
+func (r *vectorRepository) UpdateEmbedding(ctx context.Context, id uuid.UUID, embedding []float32, modelID string) error {
+	updates := map[string]interface{}{
+		"embedding": pgvector.NewVector(embedding),
+		"metadata": gorm.Expr("COALESCE(metadata, '{}'::jsonb) || jsonb_build_object('embedding_model', ?::text)", modelID),
+	}
+	if err := r.db.WithContext(ctx).Model(&domain.VectorDocument{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("failed to update embedding: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
+
+func TestValidateMetadataFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		filter domain.MetadataFilter
+		wantErr bool
+	}{
+		{"empty", nil, false},
+		{"equality", domain.MetadataFilter{{Key: "version", Op: domain.MetadataEq, Values: []interface{}{"1.0"}}}, false},
+		{"in", domain.MetadataFilter{{Key: "source", Op: domain.MetadataIn, Values: []interface{}{"a", "b"}}}, false},
+		{"range", domain.MetadataFilter{{Key: "chunk_index", Op: domain.MetadataGte, Values: []interface{}{3.0}}}, false},
+
+		{"sql in operator", domain.MetadataFilter{{Key: "chunk_index", Op: "= 1 OR 1=1 --", Values: []interface{}{3.0}}}, true},
+		{"unknown operator", domain.MetadataFilter{{Key: "chunk_index", Op: "!=", Values: []interface{}{3.0}}}, true},
+		{"no values", domain.MetadataFilter{{Key: "chunk_index", Op: domain.MetadataGt}}, true},
+		{"no values eq", domain.MetadataFilter{{Key: "version", Op: domain.MetadataEq, Values: []interface{}{}}}, true},
+		{"range with many values", domain.MetadataFilter{{Key: "chunk_index", Op: domain.MetadataLt, Values: []interface{}{1.0, 2.0}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMetadataFilter(tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validateMetadataFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// the expression builder must never panic, even on filters that skipped validation
+			metadataFilterExpr(tt.filter)
+		})
+	}
+}
@@ -11,15 +11,21 @@ import (
 type EmbeddingService interface {
 	GenerateEmbedding(ctx context.Context, text string) ([]float32, error)
 	GenerateBatchEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+	// model and output dimension, e.g. gemini-embedding-001@768. vectors of different ids are not comparable
+	ModelID() string
 }
 
 type embeddingService struct {
 	client *genai.Client
 	model string
 	dimension *int32
+	limiter RateLimiter
 }
 
-func NewEmbeddingService(cfg *config.GeminiConfig) (EmbeddingService, error) {
+// attempts per embedding request when the provider answers 429
+const embedAttempts = 3
+
+func NewEmbeddingService(cfg *config.GeminiConfig, limiter RateLimiter) (EmbeddingService, error) {
 	ctx := context.Background()
 
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: cfg.APIKey, Backend: genai.BackendGeminiAPI})
@@ -27,7 +33,14 @@ func NewEmbeddingService(cfg *config.GeminiConfig) (EmbeddingService, error) {
 		return nil, fmt.Errorf("failed to create gemini client: %w", err)
 	}
 
-	return &embeddingService{client, cfg.EmbeddingModel, cfg.Dimension}, nil
+	return &embeddingService{client, cfg.EmbeddingModel, cfg.Dimension, limiter}, nil
+}
+
+func (es *embeddingService) ModelID() string {
+	if es.dimension == nil {
+		return es.model
+	}
+	return fmt.Sprintf("%s@%d", es.model, *es.dimension)
 }
 
 func (es *embeddingService) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
@@ -35,7 +48,7 @@ func (es *embeddingService) GenerateEmbedding(ctx context.Context, text string)
 		return nil, fmt.Errorf("text cannot be empty")
 	}
 
-	res, err := es.client.Models.EmbedContent(ctx, es.model, genai.Text(text), &genai.EmbedContentConfig{OutputDimensionality: es.dimension})
+	res, err := es.embed(ctx, genai.Text(text), estimateTokens(text))
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate embedding: %w", err)
 	}
@@ -56,12 +69,14 @@ func (es *embeddingService) GenerateBatchEmbeddings(ctx context.Context, texts [
 		return nil, fmt.Errorf("texts cannot be empty")
 	}
 
+	tokens := 0
 	contents := make([]*genai.Content, len(texts))
 	for i, text := range texts {
 		contents[i] = genai.NewContentFromText(text, genai.RoleUser)
+		tokens += estimateTokens(text)
 	}
 
-	res, err := es.client.Models.EmbedContent(ctx, es.model, contents, &genai.EmbedContentConfig{OutputDimensionality: es.dimension})
+	res, err := es.embed(ctx, contents, tokens)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate batch embeddings: %w", err)
 	}
@@ -84,4 +99,24 @@ func (es *embeddingService) GenerateBatchEmbeddings(ctx context.Context, texts [
 	}
 
 	return embeddings, nil
-}
\ No newline at end of file
+}
+
+// rate limited embed request, 429 responses back off for the hinted delay and retry
+func (es *embeddingService) embed(ctx context.Context, contents []*genai.Content, tokens int) (*genai.EmbedContentResponse, error) {
+	for attempt := 1; ; attempt++ {
+		if err := es.limiter.Wait(ctx, tokens); err != nil {
+			return nil, fmt.Errorf("rate limiter: %w", err)
+		}
+
+		res, err := es.client.Models.EmbedContent(ctx, es.model, contents, &genai.EmbedContentConfig{OutputDimensionality: es.dimension})
+		if err == nil {
+			return res, nil
+		}
+
+		delay, ok := retryAfter(err)
+		if !ok || attempt == embedAttempts {
+			return nil, err
+		}
+		es.limiter.Backoff(ctx, delay)
+	}
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
+
+// claim returned by the llm with a supporting quote from the document
+type EvidenceClaim struct {
+	Claim string `json:"claim"`
+	Quote string `json:"quote"`
+}
+
+const minQuoteLength = 8
+
+// check every quote against the extracted document text, claims whose quote
+// cannot be found are kept but marked unverified
+func VerifyEvidence(text string, strengths, gaps []EvidenceClaim) []domain.Evidence {
+	normText, positions := normalizeForMatch(text)
+
+	evidence := make([]domain.Evidence, 0, len(strengths)+len(gaps))
+	groups := []struct {
+		kind string
+		claims []EvidenceClaim
+	}{
+		{domain.EvidenceStrength, strengths},
+		{domain.EvidenceGap, gaps},
+	}
+
+	for _, group := range groups {
+		for _, claim := range group.claims {
+			item := domain.Evidence{
+				Kind: group.kind,
+				Claim: strings.TrimSpace(claim.Claim),
+				Quote: strings.TrimSpace(claim.Quote),
+			}
+
+			if start, end, ok := findQuote(normText, positions, item.Quote); ok {
+				item.Verified = true
+				item.Start = &start
+				item.End = &end
+			}
+
+			evidence = append(evidence, item)
+		}
+	}
+
+	return evidence
+}
+
+// locate quote in normalized text, "..." in a quote may skip text between fragments.
+// returns rune offsets [start, end) in the original text
+func findQuote(normText string, positions []int, quote string) (int, int, bool) {
+	var fragments []string
+	for _, part := range strings.Split(strings.ReplaceAll(quote, "…", "..."), "...") {
+		norm, _ := normalizeForMatch(part)
+		if norm = strings.TrimSpace(norm); norm != "" {
+			fragments = append(fragments, norm)
+		}
+	}
+
+	if len(fragments) == 0 || utf8.RuneCountInString(strings.Join(fragments, " ")) < minQuoteLength {
+		return 0, 0, false
+	}
+
+	start, end := -1, 0
+	offset := 0
+	for _, fragment := range fragments {
+		idx := strings.Index(normText[offset:], fragment)
+		if idx < 0 {
+			return 0, 0, false
+		}
+
+		fragStart := utf8.RuneCountInString(normText[:offset+idx])
+		if start < 0 {
+			start = fragStart
+		}
+		offset += idx + len(fragment)
+		end = utf8.RuneCountInString(normText[:offset])
+	}
+
+	// map back to original rune offsets, end is exclusive
+	return positions[start], positions[end-1] + 1, true
+}
+
+// lowercase, unify quotes and dashes, collapse whitespace. positions maps every
+// rune of the normalized text to its rune index in the original
+func normalizeForMatch(text string) (string, []int) {
+	var b strings.Builder
+	positions := make([]int, 0, len(text))
+	lastSpace := true
+
+	for i, r := range []rune(text) {
+		switch {
+		case unicode.IsSpace(r):
+			if lastSpace {
+				continue
+			}
+			r = ' '
+			lastSpace = true
+		case r == '‘' || r == '’' || r == '`':
+			r = '\''
+			lastSpace = false
+		case r == '“' || r == '”':
+			r = '"'
+			lastSpace = false
+		case r == '–' || r == '—':
+			r = '-'
+			lastSpace = false
+		default:
+			r = unicode.ToLower(r)
+			lastSpace = false
+		}
+
+		b.WriteRune(r)
+		positions = append(positions, i)
+	}
+
+	return b.String(), positions
+}
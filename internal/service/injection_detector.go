@@ -0,0 +1,86 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
+
+type InjectionDetector interface {
+	Detect(source string, text string) []domain.InjectionFinding
+}
+
+type injectionPattern struct {
+	name string
+	regex *regexp.Regexp
+}
+
+// instruction-like content that has no business in a cv or project report
+var injectionPatterns = []injectionPattern{
+	{"ignore_instructions", regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+|the\s+)?(previous|prior|above|earlier|preceding|system)?\s*(instructions?|prompts?|rules|directions)\b`)},
+	{"ignore_instructions", regexp.MustCompile(`(?i)\babaikan\s+(semua\s+)?(instruksi|perintah|aturan)\b`)},
+	{"role_override", regexp.MustCompile(`(?i)\b(you are now|act as an?|pretend to be|from now on,? you)\b`)},
+	{"system_prompt", regexp.MustCompile(`(?i)\b(system prompt|system message|developer message|hidden instructions?)\b`)},
+	// addressed at the evaluator, "increased output by 100%" is an achievement
+	{"score_manipulation", regexp.MustCompile(`(?i)\b(give|assign|award|rate|score|mark|recommend)\s+(me|him|her|(this|the)\s+(candidate|applicant|cv|resume|report|submission))\b[^.\n]{0,40}(\b1\.0\b|100\s*%|\b5\s*/\s*5\b|perfect score|maximum score|highest score|full marks|strong hire)`)},
+	// plain "criteria:" is ordinary report text, only its quoted json key form counts
+	{"output_manipulation", regexp.MustCompile(`(?i)"?\b(cv_match_rate|project_score|overall_summary)\b"?\s*[:=]|"criteria"\s*:`)},
+	{"chat_markup", regexp.MustCompile(`(?i)(<\|im_start\|>|<\|im_end\|>|\[/?INST\]|<<SYS>>|###\s*(system|instruction))`)},
+	{"delimiter_spoof", untrustedDelimiterRegex},
+	{"hidden_characters", regexp.MustCompile(`[\x{200B}-\x{200F}\x{2060}\x{FEFF}]`)},
+}
+
+// tags used by the prompt templates to fence candidate documents
+var untrustedDelimiterRegex = regexp.MustCompile(`(?i)<\s*/?\s*(candidate_cv|project_report|document_section)\s*>`)
+
+const (
+	maxFindingsPerPattern = 3
+	excerptRadius = 60
+)
+
+type injectionDetector struct{}
+
+func NewInjectionDetector() InjectionDetector {
+	return &injectionDetector{}
+}
+
+func (d *injectionDetector) Detect(source string, text string) []domain.InjectionFinding {
+	var findings []domain.InjectionFinding
+
+	for _, pattern := range injectionPatterns {
+		for _, loc := range pattern.regex.FindAllStringIndex(text, maxFindingsPerPattern) {
+			findings = append(findings, domain.InjectionFinding{
+				Source: source,
+				Pattern: pattern.name,
+				Excerpt: excerpt(text, loc[0], loc[1]),
+			})
+		}
+	}
+
+	return findings
+}
+
+// neutralize our own delimiters inside untrusted text so it cannot close its data section
+func isolateUntrusted(text string) string {
+	return untrustedDelimiterRegex.ReplaceAllString(text, "[removed delimiter]")
+}
+
+func excerpt(text string, start, end int) string {
+	from := max(0, start-excerptRadius)
+	to := min(len(text), end+excerptRadius)
+
+	// keep utf-8 boundaries intact
+	for from > 0 && !isRuneStart(text[from]) {
+		from--
+	}
+	for to < len(text) && !isRuneStart(text[to]) {
+		to++
+	}
+
+	return strings.Join(strings.Fields(text[from:to]), " ")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
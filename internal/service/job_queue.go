@@ -11,8 +11,16 @@ import (
 	"github.com/sawalreverr/cv-reviewer/pkg/errors"
 )
 
+type JobKind string
+
+const (
+	JobEvaluation JobKind = "evaluation"
+	JobIngest JobKind = "kb_ingest" // ID is the knowledge base version to ingest
+)
+
 type Job struct {
 	ID uuid.UUID
+	Kind JobKind
 	JobTitle string
 	CVID uuid.UUID
 	ProjectID uuid.UUID
@@ -27,7 +35,7 @@ type JobQueue interface {
 type jobQueue struct {
 	queue chan Job
 	workerCount int
-	processor JobProcessor
+	processors map[JobKind]JobProcessor
 	wg sync.WaitGroup
 	ctx context.Context
 	cancel context.CancelFunc
@@ -37,13 +45,14 @@ type JobProcessor interface {
 	Process(ctx context.Context, job Job) error
 }
 
-func NewJobQueue(cfg *config.QueueConfig, processor JobProcessor) JobQueue {
+// one processor per job kind, workers are shared by every kind
+func NewJobQueue(cfg *config.QueueConfig, processors map[JobKind]JobProcessor) JobQueue {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &jobQueue{
 		queue: make(chan Job, cfg.QueueSize),
 		workerCount: cfg.WorkerCount,
-		processor: processor,
+		processors: processors,
 		ctx: ctx,
 		cancel: cancel,
 	}
@@ -81,8 +90,14 @@ func (q *jobQueue) worker(id int) {
 		case job, ok := <- q.queue:
 			if !ok {return}
 
-			log.Printf("worker %d: processing job %s", id, job.ID)
-			if err := q.processor.Process(q.ctx, job); err != nil {
+			processor, ok := q.processors[job.Kind]
+			if !ok {
+				log.Printf("worker %d: no processor for job %s of kind %q", id, job.ID, job.Kind)
+				continue
+			}
+
+			log.Printf("worker %d: processing %s job %s", id, job.Kind, job.ID)
+			if err := processor.Process(q.ctx, job); err != nil {
 				log.Printf("worker %d: failed to process job %s: %v", id, job.ID, err)
 			} else {
 				log.Printf("worker %d: success to process job %s", id, job.ID)
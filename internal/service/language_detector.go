@@ -0,0 +1,52 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minimum stopword hits before a language is reported
+const minLanguageHits = 5
+
+// function words indonesian and malay share, only their distinctive spellings tell them apart
+var malayCommonStopwords = []string{"dan", "yang", "di", "dengan", "untuk", "dari", "ini", "pada", "dalam", "adalah", "saya", "sebagai", "tidak", "ke", "akan", "telah", "oleh", "juga", "serta", "menggunakan"}
+
+// frequent function words per language, distinctive enough to tell them apart on a full document
+var languageStopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "in", "with", "for", "is", "on", "as", "was", "by", "that", "this", "from", "have", "are", "at", "be", "an"},
+	"id": append([]string{"bahwa", "karena", "bisa", "setelah", "saat", "sesuai"}, malayCommonStopwords...),
+	"ms": append([]string{"bahawa", "kerana", "boleh", "selepas", "semasa", "mengikut"}, malayCommonStopwords...),
+	"nl": {"de", "het", "een", "en", "van", "in", "met", "voor", "op", "is", "dat", "als", "zijn", "bij", "door", "ook", "niet", "naar"},
+	"de": {"der", "die", "das", "und", "in", "mit", "für", "von", "ist", "den", "zu", "auf", "im", "ein", "eine", "als", "bei", "nicht", "auch", "sich"},
+	"fr": {"le", "la", "les", "et", "de", "des", "en", "du", "pour", "avec", "un", "une", "dans", "est", "sur", "par", "au", "que", "qui", "plus"},
+	"es": {"el", "la", "los", "las", "y", "de", "en", "con", "para", "por", "un", "una", "del", "que", "es", "como", "al", "se", "su", "más"},
+	"pt": {"o", "a", "os", "as", "e", "de", "em", "com", "para", "por", "um", "uma", "do", "da", "que", "no", "na", "dos", "é", "não"},
+}
+
+// detect the dominant language of a document by stopword frequency,
+// returns an iso 639-1 code or "" when the text is too short to tell
+func DetectLanguage(text string) string {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		counts[word]++
+	}
+
+	best, bestHits := "", 0
+	for lang, stopwords := range languageStopwords {
+		hits := 0
+		for _, word := range stopwords {
+			hits += counts[word]
+		}
+		// ties go to the alphabetically first code, keeps detection deterministic
+		if hits > bestHits || (hits == bestHits && hits > 0 && lang < best) {
+			best, bestHits = lang, hits
+		}
+	}
+
+	if bestHits < minLanguageHits {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+type skipCacheKey struct{}
+type sampleIndexKey struct{}
+
+// disable the llm cache for calls made with this context
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey{}, true)
+}
+
+// self-consistency samples must not share one cached response,
+// the sample index is part of the cache key
+func WithSampleIndex(ctx context.Context, index int) context.Context {
+	return context.WithValue(ctx, sampleIndexKey{}, index)
+}
+
+func cacheSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipCacheKey{}).(bool)
+	return skip
+}
+
+func cacheKey(ctx context.Context, model string, temperature float32, maxTokens int32, prompt string) string {
+	sample, _ := ctx.Value(sampleIndexKey{}).(int)
+	promptHash := sha256.Sum256([]byte(prompt))
+
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s|%.3f|%d|%d|%x", model, temperature, maxTokens, sample, promptHash)))
+	return hex.EncodeToString(key[:])
+}
@@ -1,97 +1,53 @@
 package service
 
 import (
-	"fmt"
+	"context"
 	"strings"
-)
-
-func (s *llmService) CVEvaluationPrompt(cvText string, jobDescContext, rubricContext []string) string {
-	prompt := fmt.Sprintf(`
-You are an expert technical recruiter with 8+ years of experience.
-
-TASK: Evaluate the candidate CV against the provided job description and scoring rubric.
 
-JOB DESCRIPTION:
-%s
-
-CV SCORING RUBRIC:
-%s
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
 
-CANDIDATE CV:
-%s
+type feedbackLanguageKey struct{}
 
-REQUIRED OUTPUT (JSON format):
-{
-  "cv_match_rate": <0.00-1.00>,
-  "cv_feedback": "<3-5 sentences: technical strengths, skill gaps, specific recommendations>"
+// language the llm writes feedback in (iso 639-1), defaults to english
+func WithFeedbackLanguage(ctx context.Context, code string) context.Context {
+	return context.WithValue(ctx, feedbackLanguageKey{}, code)
 }
 
-RULES:
-- Score each criterion 1-5 according to rubric
-- Calculate weighted average, convert to decimal
-- Feedback must be specific, objective, actionable
-- OUTPUT ONLY JSON, No additional text`, strings.Join(jobDescContext, "\n"), strings.Join(rubricContext, "\n"), cvText)
+func feedbackLanguage(ctx context.Context) string {
+	code, _ := ctx.Value(feedbackLanguageKey{}).(string)
+	if name, ok := domain.LanguageName(code); ok {
+		return name
+	}
 
-	return prompt
+	name, _ := domain.LanguageName(domain.DefaultFeedbackLanguage)
+	return name
 }
 
-func (s *llmService) ProjectEvaluationPrompt(projectText string, caseStudyContext, rubricContext []string) string {
-	prompt := fmt.Sprintf(`
-You are a senior backend engineer reviewing a technical case study submission.
-
-IMPORTANT: This case study may be implemented in ANY backend technology stack (Node.js, Golang, Python, etc.). 
-Evaluate based on FUNCTIONALITY and QUALITY, not specific technology choices.
-
-TASK: Evaluate the candidate Project Report against the case study brief and scoring rubric.
-
-CASE STUDY BRIEF:
-%s
-
-PROJECT SCORING RUBRIC:
-%s
-
-PROJECT REPORT:
-%s
-
-REQUIRED OUTPUT (JSON format):
-{
-  "project_score": <1.0-5.0>,
-  "project_feedback": "<3-5 sentences: best aspects, technical gaps, improvement suggestions>"
+func (s *llmService) CVEvaluationPrompt(ctx context.Context, cvText string, jobDescContext, rubricContext []string) (string, error) {
+	return s.prompts.Render(PromptCVEvaluation, CVPromptData{
+		CVText: isolateUntrusted(cvText),
+		JobDescription: strings.Join(jobDescContext, "\n"),
+		Rubric: strings.Join(rubricContext, "\n"),
+		FeedbackLanguage: feedbackLanguage(ctx),
+	})
 }
 
-RULES:
-- Score each criterion 1-5 according to rubric
-- Calculate weighted average for final score
-- Feedback must be technical, constructive, evidence-based
-- OUTPUT ONLY JSON, No additional text`, strings.Join(caseStudyContext, "\n"), strings.Join(rubricContext, "\n"), projectText)
-
-
-	return prompt
+func (s *llmService) ProjectEvaluationPrompt(ctx context.Context, projectText string, caseStudyContext, rubricContext []string) (string, error) {
+	return s.prompts.Render(PromptProjectEvaluation, ProjectPromptData{
+		ProjectText: isolateUntrusted(projectText),
+		CaseStudy: strings.Join(caseStudyContext, "\n"),
+		Rubric: strings.Join(rubricContext, "\n"),
+		FeedbackLanguage: feedbackLanguage(ctx),
+	})
 }
 
-func (s *llmService) FinalSummaryPrompt(cvEval *CVEvaluation, projectEval *ProjectEvaluation) string {
-	prompt := fmt.Sprintf(`
-You are a senior engineering hiring manager synthesizing candidate evaluation results.
-
-CV EVALUATION RESULTS:
-- Match Rate: %.2f (0-1 scale)
-- Feedback: %s
-
-PROJECT EVALUATION RESULTS:
-- Overall Score: %.1f (1-5 scale)
-- Feedback: %s
-
-REQUIRED OUTPUT (JSON format):
-{
-  "overall_summary": "<3-5 sentences: holistic assessment, key strengths, critical gaps, hiring recommendation>"
+func (s *llmService) FinalSummaryPrompt(ctx context.Context, cvEval *CVEvaluation, projectEval *ProjectEvaluation) (string, error) {
+	return s.prompts.Render(PromptFinalSummary, SummaryPromptData{
+		CVMatchRate: cvEval.CVMatchRate,
+		CVFeedback: cvEval.CVFeedback,
+		ProjectScore: projectEval.ProjectScore,
+		ProjectFeedback: projectEval.ProjectFeedback,
+		FeedbackLanguage: feedbackLanguage(ctx),
+	})
 }
-
-RULES:
-- Synthesize both evaluations into coherent narrative
-- Balance technical skills (CV) with practical execution (Project)
-- Provide clear hiring recommendation (strong hire/hire/maybe/pass)
-- Be honest but professional
-- OUTPUT ONLY JSON, No additional text`, cvEval.CVMatchRate, cvEval.CVFeedback, projectEval.ProjectScore, projectEval.ProjectFeedback)
-
-	return prompt
-}
\ No newline at end of file
@@ -4,41 +4,71 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/sawalreverr/cv-reviewer/config"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
 	"google.golang.org/genai"
 )
 
 type CVEvaluation struct {
+	Criteria []domain.CriterionScore `json:"criteria"`
 	CVMatchRate float64 `json:"cv_match_rate"`
 	CVFeedback string `json:"cv_feedback"`
+	Strengths []EvidenceClaim `json:"strengths"`
+	Gaps []EvidenceClaim `json:"gaps"`
+	ReportedMatchRate float64 `json:"-"` // llm own aggregate, kept for cross-check
+	ScoreMismatch bool `json:"-"`
 }
 
 type ProjectEvaluation struct {
+	Criteria []domain.CriterionScore `json:"criteria"`
 	ProjectScore float64 `json:"project_score"`
 	ProjectFeedback string `json:"project_feedback"`
+	Strengths []EvidenceClaim `json:"strengths"`
+	Gaps []EvidenceClaim `json:"gaps"`
+	ReportedScore float64 `json:"-"` // llm own aggregate, kept for cross-check
+	ScoreMismatch bool `json:"-"`
 }
 
 type FinalSummary struct {
 	OveralSummary string `json:"overall_summary"`
+	Recommendation domain.Recommendation `json:"recommendation"`
+	KeyStrengths []string `json:"key_strengths"`
+	KeyRisks []string `json:"key_risks"`
 }
 
 type LLMService interface {
 	EvaluateCV(ctx context.Context, cvText string, jobDescContext, rubricContext []string) (*CVEvaluation, error)
 	EvaluateProject(ctx context.Context, projectText string, caseStudyContext, rubricContext []string) (*ProjectEvaluation, error)
-	FinalSummary(ctx context.Context, cvEval *CVEvaluation, projectEval *ProjectEvaluation) (string, error)
+	FinalSummary(ctx context.Context, cvEval *CVEvaluation, projectEval *ProjectEvaluation) (*FinalSummary, error)
+	FitDocument(ctx context.Context, stage domain.LLMStage, source string, text string, stageContext, rubricContext []string) (string, domain.TextStrategy, error)
+	PromptVersion() string
+	ModelStatus() []BreakerStatus
 }
 
 type llmService struct {
 	client *genai.Client
-	model string
-	temperature float32
-	maxTokens int32
+	stages map[domain.LLMStage]config.StageConfig
+	fallbacks []string
+	models []string // every model in use, for health reporting
+	breakers map[string]*CircuitBreaker
+	scoreTolerance float64
+	documentBudget int
+	sectionTokens int
+	prices map[string]config.ModelPrice
+	prompts *PromptTemplates
+	limiter RateLimiter
+	cache domain.LLMCacheRepository // nil when caching is disabled
+	cacheTTL time.Duration
+	redactAudit bool
 }
 
-func NewLLMService(cfg *config.GeminiConfig) (LLMService, error) {
+func NewLLMService(cfg *config.GeminiConfig, evalCfg *config.EvaluationConfig, pricing *config.PricingConfig, cacheCfg *config.LLMCacheConfig, cache domain.LLMCacheRepository, auditCfg *config.AuditConfig, prompts *PromptTemplates, limiter RateLimiter) (LLMService, error) {
 	ctx := context.Background()
 
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{APIKey: cfg.APIKey, Backend: genai.BackendGeminiAPI})
@@ -46,93 +76,299 @@ func NewLLMService(cfg *config.GeminiConfig) (LLMService, error) {
 		return nil, fmt.Errorf("failed to create gemini client: %w", err)
 	}
 
-	return &llmService{client, cfg.Model, cfg.Temperature, cfg.MaxTokens}, nil
+	stages := map[domain.LLMStage]config.StageConfig{
+		domain.StageCVEvaluation: cfg.CV,
+		domain.StageProjectEvaluation: cfg.Project,
+		domain.StageFinalSummary: cfg.Summary,
+		domain.StageSectionSummary: cfg.Summary,
+	}
+
+	// one breaker per distinct model, shared by every stage that uses it
+	var models []string
+	breakers := make(map[string]*CircuitBreaker)
+	for _, model := range append([]string{cfg.Model, cfg.CV.Model, cfg.Project.Model, cfg.Summary.Model}, cfg.FallbackModels...) {
+		if _, ok := breakers[model]; ok {
+			continue
+		}
+		models = append(models, model)
+		breakers[model] = NewCircuitBreaker(model, cfg.BreakerThreshold, time.Duration(cfg.BreakerCooldown)*time.Second)
+	}
+
+	return &llmService{
+		client: client,
+		stages: stages,
+		fallbacks: cfg.FallbackModels,
+		models: models,
+		breakers: breakers,
+		scoreTolerance: evalCfg.ScoreTolerance,
+		documentBudget: evalCfg.DocumentTokenBudget,
+		sectionTokens: evalCfg.SectionTokens,
+		prices: pricing.Prices,
+		prompts: prompts,
+		limiter: limiter,
+		cache: cache,
+		cacheTTL: time.Duration(cacheCfg.TTL) * time.Second,
+		redactAudit: auditCfg.Redact,
+	}, nil
 }
 
 func (s *llmService) EvaluateCV(ctx context.Context, cvText string, jobDescContext, rubricContext []string) (*CVEvaluation, error) {
-	prompt := s.CVEvaluationPrompt(cvText, jobDescContext, rubricContext)
-
-	response, err := s.generateContent(ctx, prompt)
+	prompt, err := s.CVEvaluationPrompt(ctx, cvText, jobDescContext, rubricContext)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate cv evaluation: %w", err)
+		return nil, err
 	}
 
 	// parse json response
 	var eval CVEvaluation
-	cleanedResponse := s.cleanJSONResponse(response)
-	if err := json.Unmarshal([]byte(cleanedResponse), &eval); err != nil {
-		return nil, fmt.Errorf("failed to parse cv evaluation: %w (response: %s)", err, cleanedResponse)
+	if err := s.generateContent(ctx, domain.StageCVEvaluation, prompt, func(response string) error {
+		eval = CVEvaluation{} // a rejected cached response must not leak fields
+		cleanedResponse := s.cleanJSONResponse(response)
+		if err := json.Unmarshal([]byte(cleanedResponse), &eval); err != nil {
+			return fmt.Errorf("failed to parse cv evaluation: %w", err)
+		}
+		if err := s.validateCriteria(eval.Criteria); err != nil {
+			return fmt.Errorf("invalid cv criteria: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to generate cv evaluation: %w", err)
 	}
 
-	// validate cv_match_rate is between 0 and 1
-	if eval.CVMatchRate < 0 || eval.CVMatchRate > 1 {
-		return nil, fmt.Errorf("invalid cv_match_rate: %f (must be between 0 and 1)", eval.CVMatchRate)
-	}
+	// compute match rate from criteria, llm arithmetic only used as cross-check
+	eval.ReportedMatchRate = eval.CVMatchRate
+	eval.CVMatchRate = MatchRateFromScore(WeightedScore(eval.Criteria))
+	eval.ScoreMismatch = math.Abs(eval.CVMatchRate-eval.ReportedMatchRate) > s.scoreTolerance
 
 	return &eval, nil
 }
 
 func (s *llmService) EvaluateProject(ctx context.Context, projectText string, caseStudyContext, rubricContext []string) (*ProjectEvaluation, error) {
-	prompt := s.ProjectEvaluationPrompt(projectText, caseStudyContext, rubricContext)
-
-	response, err := s.generateContent(ctx, prompt)
+	prompt, err := s.ProjectEvaluationPrompt(ctx, projectText, caseStudyContext, rubricContext)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate project evaluation: %w", err)
+		return nil, err
 	}
 
 	// parse json response
 	var eval ProjectEvaluation
-	cleanedResponse := s.cleanJSONResponse(response)
-	if err := json.Unmarshal([]byte(cleanedResponse), &eval); err != nil {
-		return nil, fmt.Errorf("failed to parse project evaluation: %w (response: %s)", err, cleanedResponse)
+	if err := s.generateContent(ctx, domain.StageProjectEvaluation, prompt, func(response string) error {
+		eval = ProjectEvaluation{}
+		cleanedResponse := s.cleanJSONResponse(response)
+		if err := json.Unmarshal([]byte(cleanedResponse), &eval); err != nil {
+			return fmt.Errorf("failed to parse project evaluation: %w", err)
+		}
+		if err := s.validateCriteria(eval.Criteria); err != nil {
+			return fmt.Errorf("invalid project criteria: %w", err)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to generate project evaluation: %w", err)
 	}
 
-	// validate project_score is between 1 and 5
-	if eval.ProjectScore < 1 || eval.ProjectScore > 5 {
-		return nil, fmt.Errorf("invalid project_score: %f (must be between 1 and 5)", eval.ProjectScore)
-	}
+	// compute score from criteria, compare on the 0-1 scale like the cv match rate
+	eval.ReportedScore = eval.ProjectScore
+	eval.ProjectScore = WeightedScore(eval.Criteria)
+	eval.ScoreMismatch = math.Abs(MatchRateFromScore(eval.ProjectScore)-MatchRateFromScore(eval.ReportedScore)) > s.scoreTolerance
 
 	return &eval, nil
 }
 
-func (s *llmService) FinalSummary(ctx context.Context, cvEval *CVEvaluation, projectEval *ProjectEvaluation) (string, error) {
-	prompt := s.FinalSummaryPrompt(cvEval, projectEval)
-
-	response, err := s.generateContent(ctx, prompt)
+func (s *llmService) FinalSummary(ctx context.Context, cvEval *CVEvaluation, projectEval *ProjectEvaluation) (*FinalSummary, error) {
+	prompt, err := s.FinalSummaryPrompt(ctx, cvEval, projectEval)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate final summary: %w", err)
-	} 
+		return nil, err
+	}
 
 	var summary FinalSummary
-	cleanedResponse := s.cleanJSONResponse(response)
-	if err := json.Unmarshal([]byte(cleanedResponse), &summary); err != nil {
-		return "", fmt.Errorf("failed to parse finaly summary: %w (response: %s)", err, cleanedResponse)
+	if err := s.generateContent(ctx, domain.StageFinalSummary, prompt, func(response string) error {
+		summary = FinalSummary{}
+		cleanedResponse := s.cleanJSONResponse(response)
+		if err := json.Unmarshal([]byte(cleanedResponse), &summary); err != nil {
+			return fmt.Errorf("failed to parse finaly summary: %w", err)
+		}
+
+		// normalize "Strong Hire", "strong-hire", ... into the enum
+		recommendation, ok := domain.ParseRecommendation(string(summary.Recommendation))
+		if !ok {
+			return fmt.Errorf("invalid recommendation: %q (must be one of strong_hire, hire, maybe, pass)", summary.Recommendation)
+		}
+		summary.Recommendation = recommendation
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to generate final summary: %w", err)
+	}
+
+	summary.OveralSummary = strings.TrimSpace(summary.OveralSummary)
+	summary.KeyStrengths = trimList(summary.KeyStrengths)
+	summary.KeyRisks = trimList(summary.KeyRisks)
+
+	return &summary, nil
+}
+
+func (s *llmService) PromptVersion() string {
+	return s.prompts.Version()
+}
+
+// breaker state per model, in fallback order
+func (s *llmService) ModelStatus() []BreakerStatus {
+	statuses := make([]BreakerStatus, 0, len(s.models))
+	for _, model := range s.models {
+		statuses = append(statuses, s.breakers[model].Status())
+	}
+	return statuses
+}
+
+// generate a response and hand it to parse, which decodes and validates it for the stage.
+// only responses parse accepts are cached, a rejected answer should not be replayed until ttl
+func (s *llmService) generateContent(ctx context.Context, stage domain.LLMStage, prompt string, parse func(response string) error) error {
+	// serve from cache when the same prompt was answered before
+	useCache := s.cache != nil && !cacheSkipped(ctx)
+	params := s.stages[stage]
+	key := cacheKey(ctx, params.Model, params.Temperature, params.MaxTokens, prompt)
+	if useCache {
+		entry, err := s.cache.Get(ctx, key)
+		if err == nil {
+			call := s.newCall(stage, entry.Model, prompt)
+			call.Cached = true
+			call.Response = s.auditText(entry.Response)
+			trackCall(ctx, call)
+
+			// entries cached before the stage validation changed may be rejected now, ask the model again
+			err = parse(entry.Response)
+			if err == nil {
+				return nil
+			}
+			s.rejectCall(call, err)
+			log.Printf("cached %s response rejected, calling model: %v", stage, err)
+		} else if err != errors.ErrNotFound {
+			log.Printf("llm cache lookup failed: %v", err)
+		}
+	}
+
+	text, call, err := s.callWithFallback(ctx, stage, prompt)
+	if err != nil {
+		return err
+	}
+
+	if err := parse(text); err != nil {
+		s.rejectCall(call, err)
+		return err
+	}
+
+	// the key names the stage model, a fallback answer must not be replayed as the primary's
+	if useCache && call.Model == params.Model {
+		if err := s.cache.Set(ctx, domain.NewLLMCacheEntry(key, call.Model, text, s.cacheTTL)); err != nil {
+			log.Printf("llm cache store failed: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// a response the stage could not parse or validate, the audit row shows the stage failed
+func (s *llmService) rejectCall(call *domain.LLMCall, err error) {
+	call.Error = s.auditText("invalid response: " + err.Error())
+}
+
+// walk the model chain, skipping models whose breaker is open.
+// only retryable errors (overload, rate limit, server error, timeout) move on to the next model
+func (s *llmService) callWithFallback(ctx context.Context, stage domain.LLMStage, prompt string) (string, *domain.LLMCall, error) {
+	var lastErr error
+	for _, model := range s.modelChain(stage) {
+		breaker := s.breakers[model]
+		if !breaker.Allow() {
+			continue
+		}
+
+		text, call, err := s.callModel(ctx, stage, model, prompt)
+		if err == nil {
+			breaker.Success()
+			return text, call, nil
+		}
+
+		// the caller gave up (job timeout, shutdown), nothing to blame on the model
+		if ctx.Err() != nil || !isRetryable(err) {
+			breaker.Release()
+			return "", call, err
+		}
+
+		breaker.Failure(err)
+		lastErr = err
+		log.Printf("llm model %s failed, trying next: %v", model, err)
 	}
 
-	return strings.TrimSpace(summary.OveralSummary), nil
+	if lastErr != nil {
+		return "", nil, fmt.Errorf("%w: %v", errors.ErrLLMUnavailable, lastErr)
+	}
+	return "", nil, errors.ErrLLMUnavailable
 }
 
-func (s *llmService) generateContent(ctx context.Context, prompt string) (string, error) {
+// stage model first, then the shared fallbacks
+func (s *llmService) modelChain(stage domain.LLMStage) []string {
+	primary := s.stages[stage].Model
+	chain := []string{primary}
+	for _, model := range s.fallbacks {
+		if model != primary {
+			chain = append(chain, model)
+		}
+	}
+	return chain
+}
+
+// single request to one model, every attempt is recorded for usage and audit
+func (s *llmService) callModel(ctx context.Context, stage domain.LLMStage, model string, prompt string) (string, *domain.LLMCall, error) {
+	// shared budget across workers, waiting is not counted as latency
+	if err := s.limiter.Wait(ctx, estimateTokens(prompt)); err != nil {
+		return "", nil, fmt.Errorf("rate limiter: %w", err)
+	}
+
+	call := s.newCall(stage, model, prompt)
+	started := time.Now()
+
+	text, err := s.requestModel(ctx, call, prompt)
+	call.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		// provider errors can echo the prompt
+		call.Error = s.auditText(err.Error())
+
+		// provider asked to slow down, hold back every caller for the hinted delay
+		if delay, ok := retryAfter(err); ok {
+			s.limiter.Backoff(ctx, delay)
+		}
+	}
+	trackCall(ctx, call)
+
+	return text, call, err
+}
+
+func (s *llmService) requestModel(ctx context.Context, call *domain.LLMCall, prompt string) (string, error) {
 	// add 2 minute timeout for llm api call
 	ctxTimeout, cancel := context.WithTimeout(ctx, 120*time.Second)
 	defer cancel()
 
 	response, err := s.client.Models.GenerateContent(
 		ctxTimeout,
-		s.model,
+		call.Model,
 		genai.Text(prompt),
 		&genai.GenerateContentConfig{
-			Temperature: &s.temperature,
-			MaxOutputTokens: s.maxTokens,
+			Temperature: &call.Temperature,
+			MaxOutputTokens: call.MaxTokens,
 		},
 	)
 	if err != nil {
-		if ctxTimeout.Err() == context.DeadlineExceeded {
-			return "", fmt.Errorf("llm api timeout after 120s")
+		// only the per-call deadline is a model timeout, a job that ran out of time is not the model's fault
+		if ctxTimeout.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return "", fmt.Errorf("llm api timeout after 120s: %w", errCallTimeout)
 		}
 		return "", err
 	}
 
+	// record token usage for cost accounting, thinking tokens are billed as output
+	if usage := response.UsageMetadata; usage != nil {
+		call.InputTokens = usage.PromptTokenCount
+		call.OutputTokens = usage.CandidatesTokenCount + usage.ThoughtsTokenCount
+		call.Cost = callCost(s.prices, call.Model, call.InputTokens, call.OutputTokens)
+	}
+
 	if len(response.Candidates) == 0 {
 		return "", fmt.Errorf("no candidates in response")
 	}
@@ -142,7 +378,58 @@ func (s *llmService) generateContent(ctx context.Context, prompt string) (string
 		return "", fmt.Errorf("empty content in response")
 	}
 
-	return response.Text(), nil
+	text := response.Text()
+	call.Response = s.auditText(text)
+
+	return text, nil
+}
+
+func (s *llmService) newCall(stage domain.LLMStage, model string, prompt string) *domain.LLMCall {
+	call := domain.NewLLMCall(stage, model, 0, 0, 0)
+	params := s.stages[stage]
+	call.Temperature = params.Temperature
+	call.MaxTokens = params.MaxTokens
+	call.Prompt = s.auditText(prompt)
+	return call
+}
+
+// text stored in the audit trail, optionally with personal data redacted
+func (s *llmService) auditText(text string) *string {
+	if s.redactAudit {
+		text = RedactPII(text)
+	}
+	return &text
+}
+
+// every criterion needs a name and a 1-5 score
+func (s *llmService) validateCriteria(criteria []domain.CriterionScore) error {
+	if len(criteria) == 0 {
+		return fmt.Errorf("no criteria scored")
+	}
+
+	for i, c := range criteria {
+		if strings.TrimSpace(c.Name) == "" {
+			return fmt.Errorf("criterion %d has no name", i)
+		}
+		if c.Score < 1 || c.Score > 5 {
+			return fmt.Errorf("criterion %q score %.1f (must be between 1 and 5)", c.Name, c.Score)
+		}
+		if c.Weight < 0 {
+			return fmt.Errorf("criterion %q has negative weight", c.Name)
+		}
+	}
+
+	return nil
+}
+
+func trimList(items []string) []string {
+	trimmed := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			trimmed = append(trimmed, item)
+		}
+	}
+	return trimmed
 }
 
 func (s *llmService) cleanJSONResponse(response string) string {
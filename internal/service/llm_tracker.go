@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/sawalreverr/cv-reviewer/config"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
+
+type trackerKey struct{}
+
+// collects every llm call made with a context, safe for parallel samples
+type CallTracker struct {
+	mu sync.Mutex
+	calls []*domain.LLMCall
+}
+
+func WithCallTracker(ctx context.Context) (context.Context, *CallTracker) {
+	tracker := &CallTracker{}
+	return context.WithValue(ctx, trackerKey{}, tracker), tracker
+}
+
+func (t *CallTracker) Calls() []*domain.LLMCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]*domain.LLMCall(nil), t.calls...)
+}
+
+// params of the calls that succeeded, one entry per stage in pipeline order
+func (t *CallTracker) StageParams() []domain.StageParams {
+	var params []domain.StageParams
+	index := make(map[domain.LLMStage]int)
+
+	for _, call := range t.Calls() {
+		if call.Error != nil {
+			continue
+		}
+
+		i, ok := index[call.Stage]
+		if !ok {
+			i = len(params)
+			index[call.Stage] = i
+			params = append(params, domain.StageParams{
+				Stage: call.Stage,
+				Temperature: call.Temperature,
+				MaxTokens: call.MaxTokens,
+			})
+		}
+		if !slices.Contains(params[i].Models, call.Model) {
+			params[i].Models = append(params[i].Models, call.Model)
+		}
+	}
+
+	return params
+}
+
+func trackCall(ctx context.Context, call *domain.LLMCall) {
+	tracker, ok := ctx.Value(trackerKey{}).(*CallTracker)
+	if !ok {
+		return
+	}
+
+	tracker.mu.Lock()
+	tracker.calls = append(tracker.calls, call)
+	tracker.mu.Unlock()
+}
+
+// usd cost of a call, models without a configured price cost 0
+func callCost(prices map[string]config.ModelPrice, model string, inputTokens, outputTokens int32) float64 {
+	price, ok := prices[model]
+	if !ok {
+		return 0
+	}
+
+	return (float64(inputTokens)*price.Input + float64(outputTokens)*price.Output) / 1_000_000
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"google.golang.org/genai"
+)
+
+// rough chars per token, used when the count tokens api is unavailable
+const charsPerToken = 4
+
+// documents whose estimate is this close to the budget (either side) are counted with the api
+const estimateMargin = 0.25
+
+type sectionSummary struct {
+	Summary string `json:"summary"`
+	KeyQuotes []string `json:"key_quotes"`
+}
+
+// count tokens of a document for the stage model and map-reduce it when the rendered prompt would be over budget.
+// stage and rubric context are the retrieved chunks that go into the same prompt (job description or case study).
+// quotes are kept verbatim in the section summaries so evidence still verifies against the original text
+func (s *llmService) FitDocument(ctx context.Context, stage domain.LLMStage, source string, text string, stageContext, rubricContext []string) (string, domain.TextStrategy, error) {
+	if s.documentBudget <= 0 {
+		return text, domain.TextFull, nil
+	}
+
+	// template and retrieved context take their share of the budget first
+	overhead, err := s.promptOverhead(ctx, stage, stageContext, rubricContext)
+	if err != nil {
+		return "", "", err
+	}
+	budget := s.documentBudget - overhead
+
+	tokens, fits := s.fitsBudget(ctx, stage, text, budget)
+	if fits {
+		return text, domain.TextFull, nil
+	}
+
+	sections := splitSections(text, s.sectionTokens)
+	log.Printf("%s is %d tokens (budget %d after %d prompt tokens), summarising %d sections", source, tokens, budget, overhead, len(sections))
+
+	// map, one summary per section
+	combined := make([]string, 0, len(sections))
+	for i, section := range sections {
+		prompt, err := s.prompts.Render(PromptSectionSummary, SectionPromptData{
+			Source: source,
+			Index: i + 1,
+			Total: len(sections),
+			Section: isolateUntrusted(section),
+		})
+		if err != nil {
+			return "", "", err
+		}
+
+		var summary sectionSummary
+		if err := s.generateContent(ctx, domain.StageSectionSummary, prompt, func(response string) error {
+			summary = sectionSummary{}
+			cleanedResponse := s.cleanJSONResponse(response)
+			if err := json.Unmarshal([]byte(cleanedResponse), &summary); err != nil {
+				return fmt.Errorf("failed to parse summary: %w", err)
+			}
+			return nil
+		}); err != nil {
+			return "", "", fmt.Errorf("failed to summarise %s section %d: %w", source, i+1, err)
+		}
+
+		combined = append(combined, formatSection(i+1, len(sections), summary))
+	}
+
+	// reduce, the evaluation prompt sees the combined section findings
+	return strings.Join(combined, "\n\n"), domain.TextMapReduce, nil
+}
+
+// estimated tokens of the stage prompt without the document
+func (s *llmService) promptOverhead(ctx context.Context, stage domain.LLMStage, stageContext, rubricContext []string) (int, error) {
+	var prompt string
+	var err error
+	switch stage {
+	case domain.StageCVEvaluation:
+		prompt, err = s.CVEvaluationPrompt(ctx, "", stageContext, rubricContext)
+	case domain.StageProjectEvaluation:
+		prompt, err = s.ProjectEvaluationPrompt(ctx, "", stageContext, rubricContext)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return estimateTokens(prompt), nil
+}
+
+// local estimate first, the count tokens api is only asked when the estimate is close to the budget
+func (s *llmService) fitsBudget(ctx context.Context, stage domain.LLMStage, text string, budget int) (int, bool) {
+	tokens := estimateTokens(text)
+	switch {
+	case float64(tokens) <= float64(budget)*(1-estimateMargin):
+		return tokens, true
+	case float64(tokens) > float64(budget)*(1+estimateMargin):
+		return tokens, false
+	}
+
+	tokens = s.countTokens(ctx, stage, text)
+	return tokens, tokens <= budget
+}
+
+// count tokens requests use the same provider quota as generate calls
+func (s *llmService) countTokens(ctx context.Context, stage domain.LLMStage, text string) int {
+	if err := s.limiter.Wait(ctx, estimateTokens(text)); err != nil {
+		log.Printf("count tokens skipped, using estimate: %v", err)
+		return estimateTokens(text)
+	}
+
+	// the text itself is audited by the generate call that follows
+	call := domain.NewLLMCall(domain.StageTokenCount, s.stages[stage].Model, 0, 0, 0)
+	started := time.Now()
+
+	response, err := s.client.Models.CountTokens(ctx, call.Model, genai.Text(text), nil)
+	call.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		call.Error = s.auditText(err.Error())
+		if delay, ok := retryAfter(err); ok {
+			s.limiter.Backoff(ctx, delay)
+		}
+	}
+	trackCall(ctx, call)
+
+	if err != nil {
+		log.Printf("count tokens failed, using estimate: %v", err)
+		return estimateTokens(text)
+	}
+
+	return int(response.TotalTokens)
+}
+
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// split on blank lines into sections of at most maxTokens (estimated),
+// paragraphs that are too long on their own are cut at rune boundaries
+func splitSections(text string, maxTokens int) []string {
+	maxChars := max(maxTokens, 1) * charsPerToken
+
+	var sections []string
+	var current strings.Builder
+	flush := func() {
+		if section := strings.TrimSpace(current.String()); section != "" {
+			sections = append(sections, section)
+		}
+		current.Reset()
+	}
+
+	for _, paragraph := range strings.Split(text, "\n\n") {
+		runes := []rune(paragraph)
+		for len(runes) > maxChars {
+			flush()
+			current.WriteString(string(runes[:maxChars]))
+			flush()
+			runes = runes[maxChars:]
+		}
+
+		if utf8.RuneCountInString(current.String())+len(runes) > maxChars {
+			flush()
+		}
+		current.WriteString(string(runes))
+		current.WriteString("\n\n")
+	}
+	flush()
+
+	return sections
+}
+
+func formatSection(index, total int, summary sectionSummary) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[section %d/%d]\n%s", index, total, strings.TrimSpace(summary.Summary))
+
+	if quotes := trimList(summary.KeyQuotes); len(quotes) > 0 {
+		b.WriteString("\nkey quotes:")
+		for _, quote := range quotes {
+			fmt.Fprintf(&b, "\n- %s", quote)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,63 @@
+package service
+
+import "math"
+
+// maximal marginal relevance, greedily pick k items that are relevant but unlike the ones
+// already picked: lambda * relevance - (1 - lambda) * max similarity to selected.
+// returns indexes into the candidates in selection order
+func SelectMMR(relevance []float64, embeddings [][]float32, k int, lambda float64) []int {
+	k = min(k, len(relevance))
+	selected := make([]int, 0, k)
+	picked := make([]bool, len(relevance))
+
+	// highest similarity of every candidate to the selected set, updated after each pick
+	redundancy := make([]float64, len(relevance))
+	for i := range redundancy {
+		redundancy[i] = math.Inf(-1)
+	}
+
+	for len(selected) < k {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range relevance {
+			if picked[i] {
+				continue
+			}
+
+			score := lambda * relevance[i]
+			if len(selected) > 0 {
+				score -= (1 - lambda) * redundancy[i]
+			}
+			// nan (bad relevance or embedding) ranks last but a candidate is always picked
+			if math.IsNaN(score) {
+				score = math.Inf(-1)
+			}
+			if best == -1 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		picked[best] = true
+		selected = append(selected, best)
+		for i := range relevance {
+			if !picked[i] {
+				redundancy[i] = math.Max(redundancy[i], CosineSimilarity(embeddings[i], embeddings[best]))
+			}
+		}
+	}
+
+	return selected
+}
+
+func CosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range min(len(a), len(b)) {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
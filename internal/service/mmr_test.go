@@ -0,0 +1,63 @@
+package service
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestSelectMMR(t *testing.T) {
+	// a and a2 are near duplicates, b points elsewhere
+	a := []float32{1, 0}
+	a2 := []float32{0.99, 0.01}
+	b := []float32{0, 1}
+
+	tests := []struct {
+		name string
+		relevance []float64
+		embeddings [][]float32
+		k int
+		lambda float64
+		want []int
+	}{
+		{"pure relevance keeps order", []float64{0.9, 0.8, 0.7}, [][]float32{a, a2, b}, 3, 1, []int{0, 1, 2}},
+		{"diversity skips near duplicate", []float64{0.9, 0.8, 0.7}, [][]float32{a, a2, b}, 2, 0.5, []int{0, 2}},
+		{"k larger than candidates", []float64{0.9, 0.8}, [][]float32{a, b}, 5, 0.5, []int{0, 1}},
+		{"k zero", []float64{0.9, 0.8}, [][]float32{a, b}, 0, 0.5, []int{}},
+		{"no candidates", nil, nil, 3, 0.5, []int{}},
+		{"nan relevance ranks last", []float64{math.NaN(), 0.8, 0.7}, [][]float32{a, a2, b}, 3, 1, []int{1, 2, 0}},
+		{"all nan", []float64{math.NaN(), math.NaN()}, [][]float32{a, b}, 2, 0.5, []int{0, 1}},
+		{"nan embedding", []float64{0.9, 0.8, 0.7}, [][]float32{a, {float32(math.NaN()), 0}, b}, 3, 0.5, []int{0, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SelectMMR(tt.relevance, tt.embeddings, tt.k, tt.lambda)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SelectMMR() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"identical", []float32{1, 2}, []float32{1, 2}, 1},
+		{"orthogonal", []float32{1, 0}, []float32{0, 1}, 0},
+		{"opposite", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"zero vector", []float32{0, 0}, []float32{1, 0}, 0},
+		{"empty", nil, nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("CosineSimilarity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/sawalreverr/cv-reviewer/config"
+)
+
+const (
+	PromptCVEvaluation = "cv_evaluation"
+	PromptProjectEvaluation = "project_evaluation"
+	PromptFinalSummary = "final_summary"
+	PromptSectionSummary = "section_summary"
+)
+
+// template data, every template is validated against these at startup
+type CVPromptData struct {
+	CVText string
+	JobDescription string
+	Rubric string
+	FeedbackLanguage string
+}
+
+type ProjectPromptData struct {
+	ProjectText string
+	CaseStudy string
+	Rubric string
+	FeedbackLanguage string
+}
+
+type SummaryPromptData struct {
+	CVMatchRate float64
+	CVFeedback string
+	ProjectScore float64
+	ProjectFeedback string
+	FeedbackLanguage string
+}
+
+type SectionPromptData struct {
+	Source string
+	Index int
+	Total int
+	Section string
+}
+
+// sample data used to validate templates on load
+var promptSamples = map[string]interface{}{
+	PromptCVEvaluation: CVPromptData{"cv", "job description", "rubric", "English"},
+	PromptProjectEvaluation: ProjectPromptData{"report", "case study", "rubric", "English"},
+	PromptFinalSummary: SummaryPromptData{0.8, "cv feedback", 4, "project feedback", "English"},
+	PromptSectionSummary: SectionPromptData{"cv", 1, 2, "section"},
+}
+
+type PromptTemplates struct {
+	version string
+	templates map[string]*template.Template
+}
+
+// load <dir>/<version>/<name>.tmpl for every required prompt, the version
+// identifier includes a content hash so edited templates never share a version
+func LoadPromptTemplates(cfg *config.PromptConfig) (*PromptTemplates, error) {
+	dir := filepath.Join(cfg.Dir, cfg.Version)
+	hash := sha256.New()
+	templates := make(map[string]*template.Template, len(promptSamples))
+
+	for _, name := range []string{PromptCVEvaluation, PromptProjectEvaluation, PromptFinalSummary, PromptSectionSummary} {
+		path := filepath.Join(dir, name+".tmpl")
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %s: %w", path, err)
+		}
+
+		tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", path, err)
+		}
+
+		// render once with sample data, catches unknown fields before any job runs
+		if err := tmpl.Execute(io.Discard, promptSamples[name]); err != nil {
+			return nil, fmt.Errorf("invalid prompt template %s: %w", path, err)
+		}
+
+		hash.Write([]byte(name))
+		hash.Write(content)
+		templates[name] = tmpl
+	}
+
+	version := fmt.Sprintf("%s+%s", cfg.Version, hex.EncodeToString(hash.Sum(nil))[:8])
+	return &PromptTemplates{version, templates}, nil
+}
+
+func (p *PromptTemplates) Version() string {
+	return p.version
+}
+
+func (p *PromptTemplates) Render(name string, data interface{}) (string, error) {
+	tmpl, ok := p.templates[name]
+	if !ok {
+		return "", fmt.Errorf("prompt template %s not loaded", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", name, err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/sawalreverr/cv-reviewer/config"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"google.golang.org/genai"
+)
+
+const (
+	rateLimitBucket = "gemini"
+	defaultRetryAfter = 5 * time.Second
+)
+
+// request and token budget per minute shared by every gemini caller
+type RateLimiter interface {
+	// block until one request with the given estimated tokens fits the budget
+	Wait(ctx context.Context, tokens int) error
+	// hold every caller back, used when the provider asks us to retry later
+	Backoff(ctx context.Context, delay time.Duration)
+}
+
+type rateLimiter struct {
+	rpm int
+	tpm int
+
+	// local bucket, used when repo is nil
+	mu sync.Mutex
+	bucket domain.RateLimitBucket
+
+	repo domain.RateLimitRepository // coordinates replicas through postgres
+}
+
+// rpm or tpm of 0 disables that limit, repo is only used when the limiter is shared.
+// with both limits off there is no budget to share, calls skip the row lock and 429 backoffs stay local
+func NewRateLimiter(cfg *config.RateLimitConfig, repo domain.RateLimitRepository) RateLimiter {
+	limiter := &rateLimiter{rpm: cfg.RPM, tpm: cfg.TPM, bucket: domain.RateLimitBucket{Name: rateLimitBucket}}
+	if cfg.Shared && (cfg.RPM > 0 || cfg.TPM > 0) {
+		limiter.repo = repo
+	}
+	return limiter
+}
+
+func (l *rateLimiter) Wait(ctx context.Context, tokens int) error {
+	for {
+		wait, err := l.take(ctx, tokens)
+		if err != nil {
+			return err
+		}
+		if wait <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *rateLimiter) Backoff(ctx context.Context, delay time.Duration) {
+	until := time.Now().Add(delay)
+	pause := func(bucket *domain.RateLimitBucket) error {
+		if until.After(bucket.PausedUntil) {
+			bucket.PausedUntil = until
+		}
+		return nil
+	}
+
+	if l.repo != nil {
+		if err := l.repo.WithLock(ctx, rateLimitBucket, pause); err != nil {
+			log.Printf("rate limit backoff failed: %v", err)
+		}
+		return
+	}
+
+	l.mu.Lock()
+	pause(&l.bucket)
+	l.mu.Unlock()
+}
+
+func (l *rateLimiter) take(ctx context.Context, tokens int) (time.Duration, error) {
+	var wait time.Duration
+	takeFn := func(bucket *domain.RateLimitBucket) error {
+		wait = takeFromBucket(bucket, time.Now(), tokens, l.rpm, l.tpm)
+		return nil
+	}
+
+	if l.repo != nil {
+		err := l.repo.WithLock(ctx, rateLimitBucket, takeFn)
+		return wait, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	takeFn(&l.bucket)
+	return wait, nil
+}
+
+// refill the bucket for the elapsed time and consume one request plus tokens,
+// returns how long to wait instead when the bucket is short or paused
+func takeFromBucket(bucket *domain.RateLimitBucket, now time.Time, tokens, rpm, tpm int) time.Duration {
+	if bucket.RefilledAt.IsZero() {
+		bucket.Requests, bucket.Tokens = float64(rpm), float64(tpm)
+	} else {
+		elapsed := now.Sub(bucket.RefilledAt).Minutes()
+		bucket.Requests = math.Min(float64(rpm), bucket.Requests+elapsed*float64(rpm))
+		bucket.Tokens = math.Min(float64(tpm), bucket.Tokens+elapsed*float64(tpm))
+	}
+	bucket.RefilledAt = now
+
+	if now.Before(bucket.PausedUntil) {
+		return bucket.PausedUntil.Sub(now)
+	}
+
+	// a single request larger than the whole budget only waits for a full bucket
+	need := float64(min(tokens, tpm))
+
+	var wait float64 // minutes
+	if rpm > 0 && bucket.Requests < 1 {
+		wait = math.Max(wait, (1-bucket.Requests)/float64(rpm))
+	}
+	if tpm > 0 && bucket.Tokens < need {
+		wait = math.Max(wait, (need-bucket.Tokens)/float64(tpm))
+	}
+	if wait > 0 {
+		return time.Duration(wait * float64(time.Minute))
+	}
+
+	if rpm > 0 {
+		bucket.Requests--
+	}
+	if tpm > 0 {
+		bucket.Tokens -= need
+	}
+	return 0
+}
+
+// delay requested by a 429 response, from the google.rpc.RetryInfo detail when present
+func retryAfter(err error) (time.Duration, bool) {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 429 {
+		return 0, false
+	}
+
+	for _, detail := range apiErr.Details {
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil && d > 0 {
+				return d, true
+			}
+		}
+	}
+
+	return defaultRetryAfter, true
+}
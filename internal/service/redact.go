@@ -0,0 +1,26 @@
+package service
+
+import "regexp"
+
+var piiPatterns = []struct {
+	regex *regexp.Regexp
+	replacement string
+}{
+	{regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`), "[email]"},
+	{regexp.MustCompile(`(?i)\b(https?://|www\.)[^\s]+`), "[url]"},
+	{regexp.MustCompile(`(?i)\b(linkedin\.com|github\.com)/[^\s]+`), "[url]"},
+	// phone numbers need a leading +, an area code in parentheses, a trunk 0 or dashed 3-3-4 groups,
+	// bare digit runs and year lists ("2018 2019 2020") are left alone
+	{regexp.MustCompile(`\+\d[\d\s.\-()]{6,}\d`), "[phone]"},
+	{regexp.MustCompile(`\(\d{2,4}\)[\s.\-]?\d{3,4}[\s.\-]?\d{3,4}\b`), "[phone]"},
+	{regexp.MustCompile(`\b0\d{2,3}[\s.\-]\d{3,4}[\s.\-]?\d{3,4}\b`), "[phone]"},
+	{regexp.MustCompile(`\b\d{3}[.\-]\d{3}[.\-]\d{4}\b`), "[phone]"},
+}
+
+// mask contact details (email, urls, phone numbers) before text is stored in the audit trail
+func RedactPII(text string) string {
+	for _, pattern := range piiPatterns {
+		text = pattern.regex.ReplaceAllString(text, pattern.replacement)
+	}
+	return text
+}
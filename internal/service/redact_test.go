@@ -0,0 +1,38 @@
+package service
+
+import "testing"
+
+func TestRedactPII(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"email", "mail me at jane.doe@example.com", "mail me at [email]"},
+		{"url", "see https://example.com/cv", "see [url]"},
+		{"profile", "github.com/janedoe", "[url]"},
+		{"international phone", "call +62 812-3456-7890 today", "call [phone] today"},
+		{"international compact", "+6281234567890", "[phone]"},
+		{"area code", "(021) 555-1234", "[phone]"},
+		{"trunk prefix", "0812 3456 7890", "[phone]"},
+		{"us dashed", "555-123-4567", "[phone]"},
+
+		// must not match
+		{"year list", "worked there 2018 2019 2020", "worked there 2018 2019 2020"},
+		{"year range", "2018-2020", "2018-2020"},
+		{"date", "joined 2024-01-15", "joined 2024-01-15"},
+		{"numeric id", "employee id 1234567890", "employee id 1234567890"},
+		{"long id", "order 20231015001", "order 20231015001"},
+		{"amount", "handled 1,000,000 requests", "handled 1,000,000 requests"},
+		{"version", "upgraded to 1.22.3", "upgraded to 1.22.3"},
+		{"ip address", "server 192.168.100.1", "server 192.168.100.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactPII(tt.text); got != tt.want {
+				t.Errorf("RedactPII(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
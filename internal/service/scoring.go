@@ -0,0 +1,96 @@
+package service
+
+import (
+	"math"
+	"sort"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
+
+// weighted average of 1-5 criterion scores, weights are normalized so
+// rubrics written in percent (40, 30, ...) or decimals (0.4, 0.3, ...) both work
+func WeightedScore(criteria []domain.CriterionScore) float64 {
+	if len(criteria) == 0 {
+		return 0
+	}
+
+	var totalWeight float64
+	for _, c := range criteria {
+		totalWeight += c.Weight
+	}
+
+	var sum float64
+	for _, c := range criteria {
+		weight := c.Weight / totalWeight
+		if totalWeight == 0 {
+			// no weights from rubric, treat criteria equally
+			weight = 1 / float64(len(criteria))
+		}
+		sum += c.Score * weight
+	}
+
+	return roundScore(sum)
+}
+
+// convert 1-5 weighted score to 0-1 match rate (score x 0.2)
+func MatchRateFromScore(score float64) float64 {
+	return roundScore(score * 0.2)
+}
+
+const (
+	AggregationMedian = "median"
+	AggregationMean = "mean"
+)
+
+// combine sampled scores with median (default) or mean
+func AggregateScores(scores []float64, method string) float64 {
+	if method == AggregationMean {
+		return roundScore(Mean(scores))
+	}
+	return roundScore(Median(scores))
+}
+
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func Median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// population standard deviation, 0 for a single sample
+func StdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+
+	mean := Mean(values)
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)))
+}
+
+func roundScore(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,30 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
+
+type AuditUsecase interface {
+	GetJobLLMCalls(ctx context.Context, jobID uuid.UUID) ([]*domain.LLMCall, error)
+}
+
+type auditUsecase struct {
+	jobRepo domain.EvaluationJobRepository
+	llmCallRepo domain.LLMCallRepository
+}
+
+func NewAuditUsecase(jobRepo domain.EvaluationJobRepository, llmCallRepo domain.LLMCallRepository) AuditUsecase {
+	return &auditUsecase{jobRepo, llmCallRepo}
+}
+
+func (uc *auditUsecase) GetJobLLMCalls(ctx context.Context, jobID uuid.UUID) ([]*domain.LLMCall, error) {
+	// job exist ?
+	if _, err := uc.jobRepo.FindByID(ctx, jobID); err != nil {
+		return nil, err
+	}
+
+	return uc.llmCallRepo.FindByJobID(ctx, jobID)
+}
@@ -4,17 +4,22 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/config"
 	"github.com/sawalreverr/cv-reviewer/internal/domain"
 	"github.com/sawalreverr/cv-reviewer/internal/service"
 	"github.com/sawalreverr/cv-reviewer/pkg/errors"
 )
 
 type EvaluationUsecase interface {
-	CreateEvaluationJob(ctx context.Context, jobTitle string, cvID, reportID uuid.UUID) (*domain.EvaluationJob, error)
+	CreateEvaluationJob(ctx context.Context, positionID uuid.UUID, jobTitle string, cvID, reportID uuid.UUID, skipCache bool, feedbackLanguage string) (*domain.EvaluationJob, error)
 	GetEvaluationJob(ctx context.Context, jobID uuid.UUID) (*domain.EvaluationJob, *domain.EvaluationResult, error)
+	ListResults(ctx context.Context, filter domain.EvaluationResultFilter) ([]*domain.EvaluationResult, int64, error)
 	Process(ctx context.Context, job service.Job) error
 }
 
@@ -22,9 +27,13 @@ type evaluationUsecase struct {
 	jobRepo domain.EvaluationJobRepository
 	resultRepo domain.EvaluationResultRepository
 	documentRepo domain.DocumentRepository
+	positionRepo domain.PositionRepository
+	llmCallRepo domain.LLMCallRepository
 	vectorUsecase VectorUsecase
 	pdfService service.PDFService
 	llmService service.LLMService
+	injectionDetector service.InjectionDetector
+	evalCfg *config.EvaluationConfig
 	jobTimeout time.Duration
 }
 
@@ -32,23 +41,58 @@ func NewEvaluationUsecase(
 	jobRepo domain.EvaluationJobRepository,
 	resultRepo domain.EvaluationResultRepository,
 	documentRepo domain.DocumentRepository,
+	positionRepo domain.PositionRepository,
+	llmCallRepo domain.LLMCallRepository,
 	vectorUsecase VectorUsecase,
 	pdfService service.PDFService,
 	llmService service.LLMService,
+	injectionDetector service.InjectionDetector,
+	evalCfg *config.EvaluationConfig,
 	jobTimeout int,
 ) EvaluationUsecase {
 	return &evaluationUsecase{
 		jobRepo: jobRepo,
 		resultRepo: resultRepo,
 		documentRepo: documentRepo,
+		positionRepo: positionRepo,
+		llmCallRepo: llmCallRepo,
 		vectorUsecase: vectorUsecase,
 		pdfService: pdfService,
 		llmService: llmService,
+		injectionDetector: injectionDetector,
+		evalCfg: evalCfg,
 		jobTimeout: time.Duration(jobTimeout) * time.Second,
 	}
 }
 
-func (uc *evaluationUsecase) CreateEvaluationJob(ctx context.Context, jobTitle string, cvID, reportID uuid.UUID) (*domain.EvaluationJob, error) {
+func (uc *evaluationUsecase) CreateEvaluationJob(ctx context.Context, positionID uuid.UUID, jobTitle string, cvID, reportID uuid.UUID, skipCache bool, feedbackLanguage string) (*domain.EvaluationJob, error) {
+	// feedback language must be one we can name in the prompt
+	if feedbackLanguage == "" {
+		feedbackLanguage = domain.DefaultFeedbackLanguage
+	}
+	if _, ok := domain.LanguageName(feedbackLanguage); !ok {
+		return nil, fmt.Errorf("%w: unsupported feedback language %q", errors.ErrInvalidInput, feedbackLanguage)
+	}
+
+	// retrieval is limited to the position knowledge base, job title falls back to the position title
+	position, err := uc.positionRepo.FindByID(ctx, positionID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(jobTitle) == "" {
+		jobTitle = position.Title
+	}
+
+	// a position without its knowledge base would be evaluated without any context
+	counts, err := uc.vectorUsecase.GetDocumentCounts(ctx, positionID)
+	if err != nil {
+		return nil, err
+	}
+	if missing := domain.MissingKnowledgeBaseTypes(counts); len(missing) > 0 {
+		log.Printf("position %s is missing knowledge base documents: %v", position.Slug, missing)
+		return nil, errors.ErrKnowledgeBaseIncomplete
+	}
+
 	// validate document exist
 	if _, err := uc.documentRepo.FindByID(ctx, cvID); err != nil {
 		return nil, fmt.Errorf("cv document not found: %w", err)
@@ -59,7 +103,7 @@ func (uc *evaluationUsecase) CreateEvaluationJob(ctx context.Context, jobTitle s
 	}
 
 	// create job
-	job := domain.NewEvaluationJob(jobTitle, cvID, reportID)
+	job := domain.NewEvaluationJob(positionID, jobTitle, cvID, reportID, skipCache, strings.ToLower(strings.TrimSpace(feedbackLanguage)))
 	if err := uc.jobRepo.Create(ctx, job); err != nil {
 		return nil, fmt.Errorf("failed to create evaluation job: %w", err)	
 	}
@@ -85,6 +129,25 @@ func (uc *evaluationUsecase) GetEvaluationJob(ctx context.Context, jobID uuid.UU
 	return job, result, nil
 }
 
+const (
+	defaultListLimit = 20
+	maxListLimit = 100
+)
+
+func (uc *evaluationUsecase) ListResults(ctx context.Context, filter domain.EvaluationResultFilter) ([]*domain.EvaluationResult, int64, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultListLimit
+	}
+	if filter.Limit > maxListLimit {
+		filter.Limit = maxListLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	return uc.resultRepo.List(ctx, filter)
+}
+
 func (uc *evaluationUsecase) Process(ctx context.Context, job service.Job) error {
 	// create timeout context
 	timeoutCtx, cancel := context.WithCancel(context.Background())
@@ -102,8 +165,17 @@ func (uc *evaluationUsecase) Process(ctx context.Context, job service.Job) error
 		return fmt.Errorf("failed to update job status: %w", err)
 	}
 
+	// track llm calls for usage accounting
+	timeoutCtx, tracker := service.WithCallTracker(timeoutCtx)
+	if evalJob.SkipCache {
+		timeoutCtx = service.WithoutCache(timeoutCtx)
+	}
+	timeoutCtx = service.WithFeedbackLanguage(timeoutCtx, evalJob.FeedbackLanguage)
+
 	// process evaluation
-	if err := uc.processEvaluation(timeoutCtx, evalJob); err != nil {
+	err = uc.processEvaluation(timeoutCtx, evalJob, tracker)
+	uc.saveLLMCalls(evalJob.ID, tracker)
+	if err != nil {
 		// mark as failed, bcz err
 		evalJob.MarkFailed(err.Error())
 		uc.jobRepo.Update(context.Background(), evalJob)
@@ -119,7 +191,7 @@ func (uc *evaluationUsecase) Process(ctx context.Context, job service.Job) error
 	return nil
 }
 
-func (uc *evaluationUsecase) processEvaluation(ctx context.Context, job *domain.EvaluationJob) error {
+func (uc *evaluationUsecase) processEvaluation(ctx context.Context, job *domain.EvaluationJob, tracker *service.CallTracker) error {
 	log.Printf("[%s] -- processing evaluation", job.ID)
 
 	// get cv document
@@ -146,42 +218,87 @@ func (uc *evaluationUsecase) processEvaluation(ctx context.Context, job *domain.
 		return fmt.Errorf("failed to extract project text: %w", err)
 	}
 
-	// retrieve relevant job description context
-	jdDocs, err := uc.vectorUsecase.SearchSimilar(ctx, job.JobTitle+" "+cvText[:min(500, len(cvText))], domain.JobDescription, 5)
+	// scan candidate documents for prompt injection, flagged jobs still get evaluated
+	findings := append(uc.injectionDetector.Detect(string(domain.CV), cvText), uc.injectionDetector.Detect(string(domain.ProjectReport), prText)...)
+	job.FlagInjection(findings)
+	if job.InjectionRisk {
+		log.Printf("[%s] -- possible prompt injection detected (%d findings)", job.ID, len(findings))
+	}
+
+	// pin the active knowledge base versions, a re-ingest during the job does not change its context.
+	// stored before the first search so the pinned versions cannot be deleted while the job runs
+	if job.KBVersions == nil {
+		if job.KBVersions, err = uc.vectorUsecase.ActiveVersions(ctx, job.PositionID); err != nil {
+			return fmt.Errorf("failed to resolve knowledge base versions: %w", err)
+		}
+		if err := uc.jobRepo.Update(ctx, job); err != nil {
+			return fmt.Errorf("failed to pin knowledge base versions: %w", err)
+		}
+	}
+	scope := domain.SearchScope{PositionID: job.PositionID, Versions: job.KBVersions}
+
+	// retrieve relevant job description context, every retrieval is required since
+	// the llm would still produce a score without any context
+	jdDocs, err := uc.vectorUsecase.Search(ctx, scope, job.JobTitle+" "+cvText[:min(500, len(cvText))], domain.JobDescription, 5, nil)
 	if err != nil {
 		return fmt.Errorf("failed to search job description: %w", err)
 	}
+	if len(jdDocs) == 0 {
+		return fmt.Errorf("%w: no job description chunks retrieved", errors.ErrKnowledgeBaseIncomplete)
+	}
 	jdContext := extractContent(jdDocs)
 
 	// retrieve relevant cv scoring rubric
-	cvRubricDocs, err := uc.vectorUsecase.SearchSimilar(ctx, "CV evaluation scoring criteria", domain.CVRubric, 3)
+	cvRubricDocs, err := uc.vectorUsecase.Search(ctx, scope, "CV evaluation scoring criteria", domain.CVRubric, 3, nil)
 	if err != nil {
 		return fmt.Errorf("failed to search cv rubric: %w", err)
 	}
-	cvRubricContext := extractContent(cvRubricDocs)
-
-	// evaluate cv
-	cvEval, err := uc.llmService.EvaluateCV(ctx, cvText, jdContext, cvRubricContext)
-	if err != nil {
-		return fmt.Errorf("failed to evaluate cv: %w", err)
+	if len(cvRubricDocs) == 0 {
+		return fmt.Errorf("%w: no cv rubric chunks retrieved", errors.ErrKnowledgeBaseIncomplete)
 	}
+	cvRubricContext := extractContent(cvRubricDocs)
 
 	// retrieve case study brief context 
-	csDocs, err := uc.vectorUsecase.SearchSimilar(ctx, prText[:min(500, len(prText))], domain.CaseStudyBrief, 5)
+	csDocs, err := uc.vectorUsecase.Search(ctx, scope, prText[:min(500, len(prText))], domain.CaseStudyBrief, 5, nil)
 	if err != nil {
 		return fmt.Errorf("failed to search case study brief: %w", err)
 	}
+	if len(csDocs) == 0 {
+		return fmt.Errorf("%w: no case study brief chunks retrieved", errors.ErrKnowledgeBaseIncomplete)
+	}
 	csContext := extractContent(csDocs)
 
 	// retrieve project scoring rubric
-	projectRubricDocs, err := uc.vectorUsecase.SearchSimilar(ctx, "Project evaluation scoring criteria", domain.ProjectRubric, 3)
+	projectRubricDocs, err := uc.vectorUsecase.Search(ctx, scope, "Project evaluation scoring criteria", domain.ProjectRubric, 3, nil)
 	if err != nil {
 		return fmt.Errorf("failed to search project rubric: %w", err)
 	}
+	if len(projectRubricDocs) == 0 {
+		return fmt.Errorf("%w: no project rubric chunks retrieved", errors.ErrKnowledgeBaseIncomplete)
+	}
 	projectRubricContext := extractContent(projectRubricDocs)
 
+	// long documents are map-reduced into section summaries, evidence is still checked against the full text.
+	// retrieved context shares the prompt budget so it is fetched first
+	cvInput, cvStrategy, err := uc.llmService.FitDocument(ctx, domain.StageCVEvaluation, string(domain.CV), cvText, jdContext, cvRubricContext)
+	if err != nil {
+		return fmt.Errorf("failed to prepare cv text: %w", err)
+	}
+	prInput, prStrategy, err := uc.llmService.FitDocument(ctx, domain.StageProjectEvaluation, string(domain.ProjectReport), prText, csContext, projectRubricContext)
+	if err != nil {
+		return fmt.Errorf("failed to prepare project text: %w", err)
+	}
+	job.CVTextStrategy = cvStrategy
+	job.ProjectTextStrategy = prStrategy
+
+	// evaluate cv
+	cvEval, cvStats, err := uc.evaluateCV(ctx, cvInput, jdContext, cvRubricContext)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate cv: %w", err)
+	}
+
 	// evaluate project
-	projectEval, err := uc.llmService.EvaluateProject(ctx, prText, csContext, projectRubricContext)
+	projectEval, projectStats, err := uc.evaluateProject(ctx, prInput, csContext, projectRubricContext)
 	if err != nil {
 		return fmt.Errorf("failed to evaluate project: %w", err)
 	}
@@ -192,8 +309,43 @@ func (uc *evaluationUsecase) processEvaluation(ctx context.Context, job *domain.
 		return fmt.Errorf("failed to generate summary: %w", err)
 	}
 
-	// save result
-	result := domain.NewEvaluationResult(job.ID, cvEval.CVMatchRate, cvEval.CVFeedback, projectEval.ProjectScore, projectEval.ProjectFeedback, summary)
+	// save result, scores are the aggregate over samples, criteria and feedback come from the representative sample
+	result := domain.NewEvaluationResult(job.ID, cvStats.Aggregate, cvEval.CVFeedback, cvEval.Criteria, projectStats.Aggregate, projectEval.ProjectFeedback, projectEval.Criteria, summary.OveralSummary)
+	result.Recommendation = summary.Recommendation
+	result.KeyStrengths = summary.KeyStrengths
+	result.KeyRisks = summary.KeyRisks
+	result.PromptVersion = uc.llmService.PromptVersion()
+	result.StageParams = tracker.StageParams()
+	result.CVLanguage = service.DetectLanguage(cvText)
+	result.ProjectLanguage = service.DetectLanguage(prText)
+
+	// verify feedback quotes against the extracted text, unverified claims stay flagged
+	result.CVEvidence = service.VerifyEvidence(cvText, cvEval.Strengths, cvEval.Gaps)
+	result.ProjectEvidence = service.VerifyEvidence(prText, projectEval.Strengths, projectEval.Gaps)
+	if unverified := countUnverified(result.CVEvidence) + countUnverified(result.ProjectEvidence); unverified > 0 {
+		log.Printf("[%s] -- %d feedback claims without a matching quote", job.ID, unverified)
+	}
+
+	result.CVReportedMatchRate = cvEval.ReportedMatchRate
+	result.ProjectReportedScore = projectEval.ReportedScore
+	result.ScoreMismatch = cvEval.ScoreMismatch || projectEval.ScoreMismatch
+	if result.ScoreMismatch {
+		log.Printf("[%s] -- llm aggregate score disagrees with computed score", job.ID)
+	}
+
+	// sampling spread, project spread compared on the 0-1 scale
+	result.SampleCount = min(cvStats.Samples, projectStats.Samples)
+	result.CVMatchRateStdDev = cvStats.StdDev
+	result.ProjectScoreStdDev = projectStats.StdDev
+	result.NeedsReview = cvStats.StdDev > uc.evalCfg.VarianceThreshold || service.MatchRateFromScore(projectStats.StdDev) > uc.evalCfg.VarianceThreshold
+	if result.NeedsReview {
+		log.Printf("[%s] -- high score variance across samples, flagged for review", job.ID)
+	}
+	if result.SampleCount < uc.sampleCount() {
+		result.NeedsReview = true
+		log.Printf("[%s] -- only %d of %d samples succeeded, flagged for review", job.ID, result.SampleCount, uc.sampleCount())
+	}
+
 	if err := uc.resultRepo.Create(ctx, result); err != nil {
 		return fmt.Errorf("failed to save evaluation result: %w", err)
 	}
@@ -202,6 +354,134 @@ func (uc *evaluationUsecase) processEvaluation(ctx context.Context, job *domain.
 	return nil
 }
 
+// persist tracked llm calls, failed jobs are billed too so this runs regardless of outcome
+func (uc *evaluationUsecase) saveLLMCalls(jobID uuid.UUID, tracker *service.CallTracker) {
+	calls := tracker.Calls()
+	for _, call := range calls {
+		call.JobID = jobID
+	}
+
+	if err := uc.llmCallRepo.CreateBatch(context.Background(), calls); err != nil {
+		log.Printf("[%s] -- failed to save llm usage: %v", jobID, err)
+	}
+}
+
+// scores of the successful samples of one stage
+type sampleStats struct {
+	Samples int
+	Aggregate float64
+	StdDev float64
+}
+
+func newSampleStats(scores []float64, method string) sampleStats {
+	return sampleStats{Samples: len(scores), Aggregate: service.AggregateScores(scores, method), StdDev: service.StdDev(scores)}
+}
+
+// run cv evaluation for every sample and aggregate the match rate,
+// returns the sample closest to the aggregate unchanged so its score, criteria and feedback stay consistent
+func (uc *evaluationUsecase) evaluateCV(ctx context.Context, cvText string, jdContext, rubricContext []string) (*service.CVEvaluation, sampleStats, error) {
+	samples, err := runSamples(ctx, uc.sampleCount(), uc.evalCfg.ParallelSamples, func(ctx context.Context) (*service.CVEvaluation, error) {
+		return uc.llmService.EvaluateCV(ctx, cvText, jdContext, rubricContext)
+	})
+	if err != nil {
+		return nil, sampleStats{}, err
+	}
+
+	scores := make([]float64, len(samples))
+	for i, sample := range samples {
+		scores[i] = sample.CVMatchRate
+	}
+
+	stats := newSampleStats(scores, uc.evalCfg.Aggregation)
+	return samples[closestScore(scores, stats.Aggregate)], stats, nil
+}
+
+// same as evaluateCV for project report, spread is on the 1-5 scale
+func (uc *evaluationUsecase) evaluateProject(ctx context.Context, prText string, csContext, rubricContext []string) (*service.ProjectEvaluation, sampleStats, error) {
+	samples, err := runSamples(ctx, uc.sampleCount(), uc.evalCfg.ParallelSamples, func(ctx context.Context) (*service.ProjectEvaluation, error) {
+		return uc.llmService.EvaluateProject(ctx, prText, csContext, rubricContext)
+	})
+	if err != nil {
+		return nil, sampleStats{}, err
+	}
+
+	scores := make([]float64, len(samples))
+	for i, sample := range samples {
+		scores[i] = sample.ProjectScore
+	}
+
+	stats := newSampleStats(scores, uc.evalCfg.Aggregation)
+	return samples[closestScore(scores, stats.Aggregate)], stats, nil
+}
+
+func (uc *evaluationUsecase) sampleCount() int {
+	if uc.evalCfg.Samples < 1 {
+		return 1
+	}
+	return uc.evalCfg.Samples
+}
+
+// run fn n times (concurrently if parallel), failed samples are skipped
+// as long as at least one succeeds
+func runSamples[T any](ctx context.Context, n int, parallel bool, fn func(ctx context.Context) (T, error)) ([]T, error) {
+	results := make([]T, n)
+	errs := make([]error, n)
+
+	if parallel && n > 1 {
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i], errs[i] = fn(service.WithSampleIndex(ctx, i))
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := 0; i < n; i++ {
+			results[i], errs[i] = fn(service.WithSampleIndex(ctx, i))
+		}
+	}
+
+	var samples []T
+	var lastErr error
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			log.Printf("sample %d/%d failed: %v", i+1, n, errs[i])
+			lastErr = errs[i]
+			continue
+		}
+		samples = append(samples, results[i])
+	}
+
+	if len(samples) == 0 {
+		return nil, lastErr
+	}
+
+	return samples, nil
+}
+
+func countUnverified(evidence []domain.Evidence) int {
+	count := 0
+	for _, item := range evidence {
+		if !item.Verified {
+			count++
+		}
+	}
+	return count
+}
+
+// index of the sample whose score is nearest to the aggregate
+func closestScore(scores []float64, target float64) int {
+	best := 0
+	for i, score := range scores {
+		if math.Abs(score-target) < math.Abs(scores[best]-target) {
+			best = i
+		}
+	}
+	return best
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -0,0 +1,188 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"mime/multipart"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/internal/service"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+)
+
+// live chunk counts and ingest history of a position knowledge base
+type KnowledgeBase struct {
+	PositionID uuid.UUID `json:"position_id"`
+	Documents map[domain.DocumentType]int64 `json:"documents"` // chunks of the active version per type
+	Versions []*domain.KBVersion `json:"versions"`
+}
+
+type KnowledgeBaseUsecase interface {
+	UploadDocument(ctx context.Context, positionID uuid.UUID, file *multipart.FileHeader, docType domain.DocumentType, metadata map[string]interface{}) (*domain.KBVersion, error)
+	GetKnowledgeBase(ctx context.Context, positionID uuid.UUID) (*KnowledgeBase, error)
+	GetVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error)
+	ListChunks(ctx context.Context, versionID uuid.UUID, limit, offset int) ([]*domain.VectorDocument, int64, error)
+	ActivateVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error)
+	DeleteVersion(ctx context.Context, versionID uuid.UUID) error
+	FailIngest(ctx context.Context, versionID uuid.UUID, cause error)
+	RecoverStaging(ctx context.Context, staleAfter time.Duration) ([]*domain.KBVersion, error)
+	Process(ctx context.Context, job service.Job) error
+}
+
+type knowledgeBaseUsecase struct {
+	positionRepo domain.PositionRepository
+	documentUsecase DocumentUsecase
+	vectorUsecase VectorUsecase
+	kbVersionRepo domain.KBVersionRepository
+	jobTimeout time.Duration
+}
+
+func NewKnowledgeBaseUsecase(positionRepo domain.PositionRepository, documentUsecase DocumentUsecase, vectorUsecase VectorUsecase, kbVersionRepo domain.KBVersionRepository, jobTimeout int) KnowledgeBaseUsecase {
+	return &knowledgeBaseUsecase{positionRepo, documentUsecase, vectorUsecase, kbVersionRepo, time.Duration(jobTimeout) * time.Second}
+}
+
+// store the pdf and create a staging version, chunks are written by the ingest job
+func (uc *knowledgeBaseUsecase) UploadDocument(ctx context.Context, positionID uuid.UUID, file *multipart.FileHeader, docType domain.DocumentType, metadata map[string]interface{}) (*domain.KBVersion, error) {
+	if !domain.IsKnowledgeBaseType(docType) {
+		return nil, errors.ErrInvalidInput
+	}
+
+	// position exist ?
+	if _, err := uc.positionRepo.FindByID(ctx, positionID); err != nil {
+		return nil, err
+	}
+
+	doc, err := uc.documentUsecase.UploadDocument(ctx, file, docType)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := uc.vectorUsecase.CreateVersion(ctx, positionID, docType, doc.Filename, &doc.ID, metadata, domain.ChunkingOptions{})
+	if err != nil {
+		_ = uc.documentUsecase.DeleteDocument(ctx, doc.ID)
+		return nil, err
+	}
+
+	return version, nil
+}
+
+func (uc *knowledgeBaseUsecase) GetKnowledgeBase(ctx context.Context, positionID uuid.UUID) (*KnowledgeBase, error) {
+	// position exist ?
+	if _, err := uc.positionRepo.FindByID(ctx, positionID); err != nil {
+		return nil, err
+	}
+
+	kb := &KnowledgeBase{PositionID: positionID, Documents: make(map[domain.DocumentType]int64, len(domain.KnowledgeBaseTypes))}
+	for _, docType := range domain.KnowledgeBaseTypes {
+		count, err := uc.vectorUsecase.GetDocumentCount(ctx, positionID, docType)
+		if err != nil {
+			return nil, err
+		}
+		kb.Documents[docType] = count
+	}
+
+	versions, err := uc.vectorUsecase.ListVersions(ctx, positionID)
+	if err != nil {
+		return nil, err
+	}
+	kb.Versions = versions
+
+	return kb, nil
+}
+
+func (uc *knowledgeBaseUsecase) GetVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error) {
+	return uc.vectorUsecase.GetVersion(ctx, versionID)
+}
+
+func (uc *knowledgeBaseUsecase) ListChunks(ctx context.Context, versionID uuid.UUID, limit, offset int) ([]*domain.VectorDocument, int64, error) {
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	limit = min(limit, maxListLimit)
+	offset = max(offset, 0)
+
+	return uc.vectorUsecase.ListVersionChunks(ctx, versionID, limit, offset)
+}
+
+func (uc *knowledgeBaseUsecase) ActivateVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error) {
+	return uc.vectorUsecase.ActivateVersion(ctx, versionID)
+}
+
+// deletes the uploaded pdf too
+func (uc *knowledgeBaseUsecase) DeleteVersion(ctx context.Context, versionID uuid.UUID) error {
+	version, err := uc.vectorUsecase.DeleteVersion(ctx, versionID)
+	if err != nil {
+		return err
+	}
+
+	if version.DocumentID != nil {
+		if err := uc.documentUsecase.DeleteDocument(ctx, *version.DocumentID); err != nil && err != errors.ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// a version whose ingest job never ran, e.g. the queue was full
+func (uc *knowledgeBaseUsecase) FailIngest(ctx context.Context, versionID uuid.UUID, cause error) {
+	version, err := uc.vectorUsecase.GetVersion(ctx, versionID)
+	if err != nil {
+		return
+	}
+
+	uc.vectorUsecase.FailVersion(ctx, version, cause)
+}
+
+// staging versions whose ingest never started or stopped refreshing its heartbeat for staleAfter,
+// e.g. the server restarted with the job in the in-memory queue. uploads that never started are returned
+// to be enqueued again, a copy of a job that is still queued fails to claim the version. the rest are failed
+func (uc *knowledgeBaseUsecase) RecoverStaging(ctx context.Context, staleAfter time.Duration) ([]*domain.KBVersion, error) {
+	versions, err := uc.kbVersionRepo.ListStale(ctx, time.Now().Add(-max(staleAfter, 3*IngestHeartbeat)))
+	if err != nil {
+		return nil, err
+	}
+
+	var retry []*domain.KBVersion
+	for _, version := range versions {
+		if version.StartedAt == nil && version.DocumentID != nil {
+			retry = append(retry, version)
+			continue
+		}
+		uc.vectorUsecase.FailVersion(ctx, version, fmt.Errorf("ingest interrupted"))
+	}
+
+	return retry, nil
+}
+
+// ingest job, embeds the uploaded document into its staging version and swaps it in
+func (uc *knowledgeBaseUsecase) Process(ctx context.Context, job service.Job) error {
+	// an upload is ingested within the job timeout like an evaluation
+	if uc.jobTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, uc.jobTimeout)
+		defer cancel()
+	}
+
+	version, err := uc.vectorUsecase.GetVersion(ctx, job.ID)
+	if err != nil {
+		return fmt.Errorf("failed to find knowledge base version: %w", err)
+	}
+	if version.Status != domain.KBStaging || version.DocumentID == nil {
+		return fmt.Errorf("knowledge base version %s is %s, nothing to ingest", version.ID, version.Status)
+	}
+
+	doc, err := uc.documentUsecase.GetDocument(ctx, *version.DocumentID)
+	if err != nil {
+		uc.vectorUsecase.FailVersion(ctx, version, err)
+		return fmt.Errorf("failed to get knowledge base document: %w", err)
+	}
+
+	if _, err := uc.vectorUsecase.IngestVersion(ctx, version, doc.FilePath); err != nil {
+		return fmt.Errorf("failed to ingest knowledge base version: %w", err)
+	}
+
+	return nil
+}
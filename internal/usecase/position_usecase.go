@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// position with chunk counts of its knowledge base per document type
+type PositionDetail struct {
+	*domain.Position
+	KnowledgeBase map[domain.DocumentType]int64 `json:"knowledge_base"`
+}
+
+type PositionUsecase interface {
+	CreatePosition(ctx context.Context, slug, title, description string) (*domain.Position, error)
+	GetPosition(ctx context.Context, id uuid.UUID) (*PositionDetail, error)
+	GetPositionBySlug(ctx context.Context, slug string) (*domain.Position, error)
+	ListPositions(ctx context.Context) ([]*PositionDetail, error)
+}
+
+type positionUsecase struct {
+	positionRepo domain.PositionRepository
+	vectorRepo domain.VectorRepository
+}
+
+func NewPositionUsecase(positionRepo domain.PositionRepository, vectorRepo domain.VectorRepository) PositionUsecase {
+	return &positionUsecase{positionRepo, vectorRepo}
+}
+
+func (uc *positionUsecase) CreatePosition(ctx context.Context, slug, title, description string) (*domain.Position, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	title = strings.TrimSpace(title)
+	// slug is lowercase letters, digits and dashes
+	if !slugPattern.MatchString(slug) || title == "" {
+		return nil, errors.ErrInvalidInput
+	}
+
+	// slug is unique
+	if _, err := uc.positionRepo.FindBySlug(ctx, slug); err == nil {
+		return nil, errors.ErrPositionExists
+	} else if err != errors.ErrPositionNotFound {
+		return nil, err
+	}
+
+	position := domain.NewPosition(slug, title, strings.TrimSpace(description))
+	if err := uc.positionRepo.Create(ctx, position); err != nil {
+		return nil, err
+	}
+
+	return position, nil
+}
+
+func (uc *positionUsecase) GetPosition(ctx context.Context, id uuid.UUID) (*PositionDetail, error) {
+	position, err := uc.positionRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.detail(ctx, position)
+}
+
+func (uc *positionUsecase) GetPositionBySlug(ctx context.Context, slug string) (*domain.Position, error) {
+	return uc.positionRepo.FindBySlug(ctx, slug)
+}
+
+func (uc *positionUsecase) ListPositions(ctx context.Context) ([]*PositionDetail, error) {
+	positions, err := uc.positionRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]*PositionDetail, 0, len(positions))
+	for _, position := range positions {
+		detail, err := uc.detail(ctx, position)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, detail)
+	}
+
+	return details, nil
+}
+
+func (uc *positionUsecase) detail(ctx context.Context, position *domain.Position) (*PositionDetail, error) {
+	counts, err := uc.vectorRepo.CountByPosition(ctx, position.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PositionDetail{Position: position, KnowledgeBase: counts}, nil
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+)
+
+type JobUsage struct {
+	JobID uuid.UUID `json:"job_id"`
+	Stages []*domain.UsageSummary `json:"stages"`
+	Total domain.UsageSummary `json:"total"`
+}
+
+type UsageUsecase interface {
+	GetJobUsage(ctx context.Context, jobID uuid.UUID) (*JobUsage, error)
+	GetDailyUsage(ctx context.Context, from, to time.Time) ([]*domain.UsageSummary, error)
+}
+
+type usageUsecase struct {
+	jobRepo domain.EvaluationJobRepository
+	llmCallRepo domain.LLMCallRepository
+}
+
+func NewUsageUsecase(jobRepo domain.EvaluationJobRepository, llmCallRepo domain.LLMCallRepository) UsageUsecase {
+	return &usageUsecase{jobRepo, llmCallRepo}
+}
+
+func (uc *usageUsecase) GetJobUsage(ctx context.Context, jobID uuid.UUID) (*JobUsage, error) {
+	// job exist ?
+	if _, err := uc.jobRepo.FindByID(ctx, jobID); err != nil {
+		return nil, err
+	}
+
+	stages, err := uc.llmCallRepo.SumByJob(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &JobUsage{JobID: jobID, Stages: stages}
+	for _, stage := range stages {
+		usage.Total.Calls += stage.Calls
+		usage.Total.CachedCalls += stage.CachedCalls
+		usage.Total.InputTokens += stage.InputTokens
+		usage.Total.OutputTokens += stage.OutputTokens
+		usage.Total.Cost += stage.Cost
+	}
+
+	return usage, nil
+}
+
+// daily totals for [from, to)
+func (uc *usageUsecase) GetDailyUsage(ctx context.Context, from, to time.Time) ([]*domain.UsageSummary, error) {
+	return uc.llmCallRepo.SumByDay(ctx, from, to)
+}
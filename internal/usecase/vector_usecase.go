@@ -2,94 +2,507 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"log"
+	"path/filepath"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/config"
 	"github.com/sawalreverr/cv-reviewer/internal/domain"
 	"github.com/sawalreverr/cv-reviewer/internal/service"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
 )
 
+const (
+	RetrievalVector = "vector"
+	RetrievalHybrid = "hybrid"
+)
+
+// how often a running ingest refreshes the heartbeat of its version
+const IngestHeartbeat = time.Minute
+
 type VectorUsecase interface {
-	IngestDocument(ctx context.Context, filePath string, docType domain.DocumentType, metadata map[string]interface{}) error
-	SearchSimilar(ctx context.Context, query string, docType domain.DocumentType, topK int) ([]*domain.VectorDocument, error)
-	DeleteDocumentsByType(ctx context.Context, docType domain.DocumentType) error
-	GetDocumentCount(ctx context.Context, docType domain.DocumentType) (int64, error)
+	IngestDocument(ctx context.Context, positionID uuid.UUID, filePath string, docType domain.DocumentType, metadata map[string]interface{}, chunking domain.ChunkingOptions) (*domain.KBVersion, error)
+	ChunkDocument(filePath string, chunking domain.ChunkingOptions) ([]service.TextChunk, error)
+	CreateVersion(ctx context.Context, positionID uuid.UUID, docType domain.DocumentType, source string, documentID *uuid.UUID, metadata map[string]interface{}, chunking domain.ChunkingOptions) (*domain.KBVersion, error)
+	IngestVersion(ctx context.Context, version *domain.KBVersion, filePath string) (*domain.KBVersion, error)
+	FailVersion(ctx context.Context, version *domain.KBVersion, cause error)
+	GetVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error)
+	ListVersionChunks(ctx context.Context, versionID uuid.UUID, limit, offset int) ([]*domain.VectorDocument, int64, error)
+	DeleteVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error)
+	DeleteDocumentsByType(ctx context.Context, positionID uuid.UUID, docType domain.DocumentType) (int, error)
+	ReembedVersion(ctx context.Context, versionID uuid.UUID, batchSize int) (*domain.KBVersion, error)
+	ActivateVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error)
+	ListVersions(ctx context.Context, positionID uuid.UUID) ([]*domain.KBVersion, error)
+	ActiveVersions(ctx context.Context, positionID uuid.UUID) (domain.KBVersionPins, error)
+	Search(ctx context.Context, scope domain.SearchScope, query string, docType domain.DocumentType, topK int, filter domain.MetadataFilter) ([]*domain.VectorDocument, error)
+	SearchSimilar(ctx context.Context, scope domain.SearchScope, query string, docType domain.DocumentType, topK int, filter domain.MetadataFilter) ([]*domain.VectorDocument, error)
+	SearchHybrid(ctx context.Context, scope domain.SearchScope, query string, docType domain.DocumentType, topK int, filter domain.MetadataFilter) ([]*domain.VectorDocument, error)
+	GetDocumentCount(ctx context.Context, positionID uuid.UUID, docType domain.DocumentType) (int64, error)
+	GetDocumentCounts(ctx context.Context, positionID uuid.UUID) (map[domain.DocumentType]int64, error)
+	CheckDimension(ctx context.Context, dimension int) error
+	ReembedMissing(ctx context.Context, batchSize int) (int, error)
 }
 
 type vectorUsecase struct {
 	repo domain.VectorRepository
+	kbVersionRepo domain.KBVersionRepository
 	pdfService service.PDFService 
 	chunkingService service.ChunkingService
 	embeddingService service.EmbeddingService 
+	retrievalCfg *config.RetrievalConfig
+	metadataFilter domain.MetadataFilter // RETRIEVAL_METADATA_FILTER
 }
 
-func NewVectorUsecase(repo domain.VectorRepository, pdf service.PDFService, chunk service.ChunkingService, embed service.EmbeddingService) VectorUsecase {
-	return &vectorUsecase{repo, pdf, chunk, embed}
+// RETRIEVAL_METADATA_FILTER is validated with domain.ParseMetadataFilter at startup
+func NewVectorUsecase(repo domain.VectorRepository, kbVersionRepo domain.KBVersionRepository, pdf service.PDFService, chunk service.ChunkingService, embed service.EmbeddingService, retrievalCfg *config.RetrievalConfig) VectorUsecase {
+	metadataFilter, _ := domain.ParseMetadataFilter(retrievalCfg.MetadataFilter)
+	return &vectorUsecase{repo, kbVersionRepo, pdf, chunk, embed, retrievalCfg, metadataFilter}
 }
 
-func (uc *vectorUsecase) IngestDocument(ctx context.Context, filePath string, docType domain.DocumentType, metadata map[string]interface{}) error {
+// ingest into a new staging version and swap it in when every chunk is stored,
+// searches keep reading the previous version until then. a failed ingest is kept as failed
+func (uc *vectorUsecase) IngestDocument(ctx context.Context, positionID uuid.UUID, filePath string, docType domain.DocumentType, metadata map[string]interface{}, chunking domain.ChunkingOptions) (*domain.KBVersion, error) {
+	version, err := uc.CreateVersion(ctx, positionID, docType, filepath.Base(filePath), nil, metadata, chunking)
+	if err != nil {
+		return nil, err
+	}
+
+	return uc.IngestVersion(ctx, version, filePath)
+}
+
+// staging version without chunks, ingested later by IngestVersion
+func (uc *vectorUsecase) CreateVersion(ctx context.Context, positionID uuid.UUID, docType domain.DocumentType, source string, documentID *uuid.UUID, metadata map[string]interface{}, chunking domain.ChunkingOptions) (*domain.KBVersion, error) {
+	version := domain.NewKBVersion(positionID, docType, source, documentID, metadata, chunking)
+	if err := uc.kbVersionRepo.CreateStaged(ctx, version); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+func (uc *vectorUsecase) IngestVersion(ctx context.Context, version *domain.KBVersion, filePath string) (*domain.KBVersion, error) {
+	release, err := uc.claimVersion(ctx, version)
+	if err != nil {
+		return version, err
+	}
+	defer release()
+
+	chunkCount, err := uc.stageChunks(ctx, version, filePath)
+	if err != nil {
+		uc.FailVersion(ctx, version, err)
+		return version, err
+	}
+
+	version.ChunkCount = chunkCount
+	if err := uc.kbVersionRepo.Update(ctx, version); err != nil {
+		return version, err
+	}
+
+	return uc.kbVersionRepo.Activate(ctx, version.ID)
+}
+
+// claims a staging version for this ingest and refreshes its heartbeat until release is called,
+// a second ingest of the same version fails with ErrKBVersionClaimed
+func (uc *vectorUsecase) claimVersion(ctx context.Context, version *domain.KBVersion) (release func(), err error) {
+	claimed, err := uc.kbVersionRepo.Claim(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, errors.ErrKBVersionClaimed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(IngestHeartbeat)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := uc.kbVersionRepo.Heartbeat(ctx, version.ID); err != nil {
+					log.Printf("failed to refresh heartbeat of knowledge base version %s: %v", version.ID, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
+	return cancel, nil
+}
+
+// extract and chunk a pdf without storing anything, also used for dry runs
+func (uc *vectorUsecase) ChunkDocument(filePath string, chunking domain.ChunkingOptions) ([]service.TextChunk, error) {
 	// extract text from pdf
 	text, err := uc.pdfService.ExtractText(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to extract text: %w", err)
+		return nil, fmt.Errorf("failed to extract text: %w", err)
 	}	
 
 	if text == "" {
-		return fmt.Errorf("no text extracted from document")
+		return nil, fmt.Errorf("no text extracted from document")
 	}
 
 	// chunk text
-	chunks := uc.chunkingService.ChunkBySentence(text, 1000)
+	var chunks []service.TextChunk
+	switch chunking.Strategy {
+	case "", domain.ChunkSentence:
+		chunks = uc.chunkingService.ChunkBySentence(text, chunking.Size)
+	case domain.ChunkFixed:
+		chunks = uc.chunkingService.ChunkText(text, chunking.Size, chunking.Overlap)
+	default:
+		return nil, fmt.Errorf("unknown chunking strategy %q", chunking.Strategy)
+	}
+
 	if len(chunks) == 0 {
-		return fmt.Errorf("no chunks created from text")
+		return nil, fmt.Errorf("no chunks created from text")
 	}
 
-	// generate embeddings
-	chunkContents := make([]string, len(chunks))
-	for i, chunk := range chunks {
-		chunkContents[i] = chunk.Content
+	return chunks, nil
+}
+
+// staged chunks are useless, only the failed version row is kept.
+// runs even when ctx was cancelled, e.g. on shutdown mid ingest
+func (uc *vectorUsecase) FailVersion(ctx context.Context, version *domain.KBVersion, cause error) {
+	ctx = context.WithoutCancel(ctx)
+	msg := cause.Error()
+	version.Status = domain.KBFailed
+	version.ErrorMessage = &msg
+	if err := uc.repo.DeleteByVersion(ctx, version.ID); err != nil {
+		log.Printf("failed to clean up chunks of knowledge base version %s: %v", version.ID, err)
 	}
+	if err := uc.kbVersionRepo.Update(ctx, version); err != nil {
+		log.Printf("failed to mark knowledge base version %s as failed: %v", version.ID, err)
+	}
+}
 
-	embeddings, err := uc.embeddingService.GenerateBatchEmbeddings(ctx, chunkContents)
+// extract, chunk, embed and store the chunks of a staging version, returns the number of chunks stored
+func (uc *vectorUsecase) stageChunks(ctx context.Context, version *domain.KBVersion, filePath string) (int, error) {
+	chunks, err := uc.ChunkDocument(filePath, version.Chunking)
 	if err != nil {
-		return fmt.Errorf("failed to generate batch embeddings: %w", err)
+		return 0, err
+	}
+
+	// unchanged chunks reuse the embeddings of the active version, only new content is embedded.
+	// vectors of another embedding model or dimension are never reused
+	previousDocs, err := uc.previousChunks(ctx, version)
+	if err != nil {
+		return 0, err
+	}
+	modelID := uc.embeddingService.ModelID()
+	previous := make(map[string][]float32, len(previousDocs))
+	stale := make(map[string]bool) // same content, embedded by another model
+	for _, doc := range previousDocs {
+		hash, ok := doc.Metadata["content_hash"].(string)
+		if !ok {
+			continue
+		}
+		if doc.Metadata["embedding_model"] == modelID {
+			previous[hash] = doc.Embedding.Slice()
+		} else {
+			stale[hash] = true
+		}
+	}
+
+	hashes := make([]string, len(chunks))
+	embeddings := make([][]float32, len(chunks))
+	var missing []string
+	missingIndex := make(map[string]int) // hash -> index into missing, duplicate chunks are embedded once
+	for i, chunk := range chunks {
+		hashes[i] = contentHash(chunk.Content)
+		if embedding, ok := previous[hashes[i]]; ok {
+			embeddings[i] = embedding
+			version.Report.Unchanged++
+			continue
+		}
+
+		if stale[hashes[i]] {
+			version.Report.Reembedded++
+		} else {
+			version.Report.Added++
+		}
+		if _, ok := missingIndex[hashes[i]]; !ok {
+			missingIndex[hashes[i]] = len(missing)
+			missing = append(missing, chunk.Content)
+		}
 	}
 
-	if len(embeddings) != len(chunks) {
-		return fmt.Errorf("embeddings count mismatch: got %d, expected %d", len(embeddings), len(chunks))
+	if len(missing) > 0 {
+		generated, err := uc.embeddingService.GenerateBatchEmbeddings(ctx, missing)
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate batch embeddings: %w", err)
+		}
+
+		if len(generated) != len(missing) {
+			return 0, fmt.Errorf("embeddings count mismatch: got %d, expected %d", len(generated), len(missing))
+		}
+
+		for i := range chunks {
+			if embeddings[i] == nil {
+				embeddings[i] = generated[missingIndex[hashes[i]]]
+			}
+		}
+	}
+
+	// previous chunks whose content is gone
+	current := make(map[string]bool, len(hashes))
+	for _, hash := range hashes {
+		current[hash] = true
+	}
+	for _, doc := range previousDocs {
+		if hash, _ := doc.Metadata["content_hash"].(string); !current[hash] {
+			version.Report.Removed++
+		}
 	}
 
 	// store chunks
-	successCount := 0
+	vectorDocs := make([]*domain.VectorDocument, len(chunks))
 	for i, chunk := range chunks {
 
 		// merge metadata
 		chunkMetadata := make(map[string]interface{})
-		for k, v := range metadata {
+		for k, v := range version.Metadata {
 			chunkMetadata[k] = v
 		}
 		chunkMetadata["chunk_index"] = chunk.Index
 		chunkMetadata["chunk_length"] = len(chunk.Content)
+		chunkMetadata["content_hash"] = hashes[i]
+		chunkMetadata["embedding_model"] = modelID
+		chunkMetadata["kb_version"] = version.Version
 
 		// create vector document with pregenerated embedding
-		vectorDoc := domain.NewVectorDocument(docType, chunk.Content, embeddings[i], chunkMetadata)
+		vectorDocs[i] = domain.NewVectorDocument(version.PositionID, version.ID, version.DocType, chunk.Content, embeddings[i], chunkMetadata)
+	}
+
+	if err := uc.repo.CreateBatch(ctx, vectorDocs); err != nil {
+		return 0, err
+	}
+
+	return len(vectorDocs), nil
+}
+
+// embedded chunks of the version that is active for the same position and document type, nil on first ingest
+func (uc *vectorUsecase) previousChunks(ctx context.Context, version *domain.KBVersion) ([]*domain.VectorDocument, error) {
+	active, err := uc.kbVersionRepo.FindActive(ctx, version.PositionID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range active {
+		if candidate.DocType == version.DocType {
+			return uc.repo.FindByVersion(ctx, candidate.ID)
+		}
+	}
+
+	return nil, nil
+}
+
+// sha256 of the chunk text, stored in chunk metadata as content_hash
+func contentHash(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// make an ingested version live again, e.g. to roll back a bad ingest
+func (uc *vectorUsecase) ActivateVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error) {
+	return uc.kbVersionRepo.Activate(ctx, versionID)
+}
+
+func (uc *vectorUsecase) GetVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error) {
+	return uc.kbVersionRepo.FindByID(ctx, versionID)
+}
+
+func (uc *vectorUsecase) ListVersionChunks(ctx context.Context, versionID uuid.UUID, limit, offset int) ([]*domain.VectorDocument, int64, error) {
+	if _, err := uc.kbVersionRepo.FindByID(ctx, versionID); err != nil {
+		return nil, 0, err
+	}
 
-		// store in db
-		if err := uc.repo.Create(ctx, vectorDoc); err != nil {
+	return uc.repo.ListByVersion(ctx, versionID, limit, offset)
+}
+
+// remove an archived or failed version with its chunks, the active version and running ingests are kept
+func (uc *vectorUsecase) DeleteVersion(ctx context.Context, versionID uuid.UUID) (*domain.KBVersion, error) {
+	version, err := uc.kbVersionRepo.FindByID(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if version.Status == domain.KBActive || version.Status == domain.KBStaging {
+		return nil, errors.ErrKBVersionInUse
+	}
+	// a running evaluation still searches the archived version it pinned
+	pinned, err := uc.kbVersionRepo.IsPinned(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	if pinned {
+		return nil, errors.ErrKBVersionInUse
+	}
+
+	if err := uc.repo.DeleteByVersion(ctx, versionID); err != nil {
+		return nil, err
+	}
+	if err := uc.kbVersionRepo.Delete(ctx, versionID); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
+
+// drop every version of a document type with its chunks, the position has no such context afterwards
+func (uc *vectorUsecase) DeleteDocumentsByType(ctx context.Context, positionID uuid.UUID, docType domain.DocumentType) (int, error) {
+	versions, err := uc.kbVersionRepo.ListByPosition(ctx, positionID)
+	if err != nil {
+		return 0, err
+	}
+
+	var matched []*domain.KBVersion
+	for _, version := range versions {
+		if version.DocType != docType {
 			continue
 		}
 
-		successCount++
+		pinned, err := uc.kbVersionRepo.IsPinned(ctx, version)
+		if err != nil {
+			return 0, err
+		}
+		if pinned {
+			return 0, errors.ErrKBVersionInUse
+		}
+		matched = append(matched, version)
 	}
 
-	if successCount == 0 {
-		return fmt.Errorf("failed to store any chunks")
+	deleted := 0
+	for _, version := range matched {
+		if err := uc.repo.DeleteByVersion(ctx, version.ID); err != nil {
+			return deleted, err
+		}
+		if err := uc.kbVersionRepo.Delete(ctx, version.ID); err != nil {
+			return deleted, err
+		}
+		deleted++
 	}
 
-	return nil
+	return deleted, nil
+}
+
+// embed the chunks of a version again into a new staging version and swap it in, e.g. after switching the embedding model.
+// searches keep reading the current version until every chunk is stored, a failed re-embed is kept as failed
+func (uc *vectorUsecase) ReembedVersion(ctx context.Context, versionID uuid.UUID, batchSize int) (*domain.KBVersion, error) {
+	source, err := uc.kbVersionRepo.FindByID(ctx, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if source.Status != domain.KBActive && source.Status != domain.KBArchived {
+		return nil, errors.ErrKBVersionNotReady
+	}
+
+	// no uploaded pdf, deleting the source version removes it
+	version, err := uc.CreateVersion(ctx, source.PositionID, source.DocType, source.Source, nil, source.Metadata, source.Chunking)
+	if err != nil {
+		return nil, err
+	}
+	release, err := uc.claimVersion(ctx, version)
+	if err != nil {
+		return version, err
+	}
+	defer release()
+
+	chunkCount, err := uc.copyChunks(ctx, source, version, batchSize)
+	if err != nil {
+		uc.FailVersion(ctx, version, err)
+		return version, err
+	}
+
+	version.ChunkCount = chunkCount
+	version.Report = domain.IngestReport{Reembedded: chunkCount}
+	if err := uc.kbVersionRepo.Update(ctx, version); err != nil {
+		return version, err
+	}
+
+	return uc.kbVersionRepo.Activate(ctx, version.ID)
+}
+
+// chunks of source with fresh embeddings, stored under version. returns the number of chunks stored
+func (uc *vectorUsecase) copyChunks(ctx context.Context, source, version *domain.KBVersion, batchSize int) (int, error) {
+	modelID := uc.embeddingService.ModelID()
+	var vectorDocs []*domain.VectorDocument
+	for {
+		docs, _, err := uc.repo.ListByVersion(ctx, source.ID, batchSize, len(vectorDocs))
+		if err != nil {
+			return 0, err
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		contents := make([]string, len(docs))
+		for i, doc := range docs {
+			contents[i] = doc.Content
+		}
+
+		embeddings, err := uc.embeddingService.GenerateBatchEmbeddings(ctx, contents)
+		if err != nil {
+			return 0, fmt.Errorf("failed to generate batch embeddings: %w", err)
+		}
+		if len(embeddings) != len(docs) {
+			return 0, fmt.Errorf("embeddings count mismatch: got %d, expected %d", len(embeddings), len(docs))
+		}
+
+		for i, doc := range docs {
+			chunkMetadata := make(map[string]interface{}, len(doc.Metadata))
+			for k, v := range doc.Metadata {
+				chunkMetadata[k] = v
+			}
+			chunkMetadata["embedding_model"] = modelID
+			chunkMetadata["kb_version"] = version.Version
+
+			vectorDocs = append(vectorDocs, domain.NewVectorDocument(version.PositionID, version.ID, version.DocType, doc.Content, embeddings[i], chunkMetadata))
+		}
+	}
+
+	if len(vectorDocs) == 0 {
+		return 0, fmt.Errorf("knowledge base version %s has no chunks", source.ID)
+	}
+	if err := uc.repo.CreateBatch(ctx, vectorDocs); err != nil {
+		return 0, err
+	}
+
+	return len(vectorDocs), nil
 }
 
-func (uc *vectorUsecase) SearchSimilar(ctx context.Context, query string, docType domain.DocumentType, topK int) ([]*domain.VectorDocument, error) {
+func (uc *vectorUsecase) ListVersions(ctx context.Context, positionID uuid.UUID) ([]*domain.KBVersion, error) {
+	return uc.kbVersionRepo.ListByPosition(ctx, positionID)
+}
+
+// active version per document type, pinned by evaluation jobs so a swap mid job does not mix versions
+func (uc *vectorUsecase) ActiveVersions(ctx context.Context, positionID uuid.UUID) (domain.KBVersionPins, error) {
+	versions, err := uc.kbVersionRepo.FindActive(ctx, positionID)
+	if err != nil {
+		return nil, err
+	}
+
+	pins := make(domain.KBVersionPins, len(versions))
+	for _, version := range versions {
+		pins[version.DocType] = version.ID
+	}
+
+	return pins, nil
+}
+
+// search with the configured retrieval mode, anything but hybrid is pure vector
+func (uc *vectorUsecase) Search(ctx context.Context, scope domain.SearchScope, query string, docType domain.DocumentType, topK int, filter domain.MetadataFilter) ([]*domain.VectorDocument, error) {
+	if uc.retrievalCfg.Mode == RetrievalHybrid {
+		return uc.SearchHybrid(ctx, scope, query, docType, topK, filter)
+	}
+	return uc.SearchSimilar(ctx, scope, query, docType, topK, filter)
+}
+
+func (uc *vectorUsecase) SearchSimilar(ctx context.Context, scope domain.SearchScope, query string, docType domain.DocumentType, topK int, filter domain.MetadataFilter) ([]*domain.VectorDocument, error) {
 	// generate embedding for query
 	queryEmbedding, err := uc.embeddingService.GenerateEmbedding(ctx, query)
 	if err != nil {
@@ -97,18 +510,144 @@ func (uc *vectorUsecase) SearchSimilar(ctx context.Context, query string, docTyp
 	}
 
 	// search similar
-	docs, err := uc.repo.SearchSimilar(ctx, queryEmbedding, docType, topK)
+	docs, err := uc.repo.SearchSimilar(ctx, queryEmbedding, docType, uc.fetchLimit(topK), uc.searchOptions(scope, docType, filter))
 	if err != nil {
 		return nil, fmt.Errorf("failed to search similar documents: %w", err)
 	}
 
-	return docs, nil
+	return uc.diversify(docs, topK, func(doc *domain.VectorDocument) float64 { return doc.Similarity }), nil
 }
 
-func (uc *vectorUsecase) DeleteDocumentsByType(ctx context.Context, docType domain.DocumentType) error {
-	return uc.repo.DeleteByDocType(ctx, docType)
+// vector similarity fused with postgres full-text rank, catches exact keywords like technology names
+func (uc *vectorUsecase) SearchHybrid(ctx context.Context, scope domain.SearchScope, query string, docType domain.DocumentType, topK int, filter domain.MetadataFilter) ([]*domain.VectorDocument, error) {
+	queryEmbedding, err := uc.embeddingService.GenerateEmbedding(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate query embedding: %w", err)
+	}
+
+	docs, err := uc.repo.SearchHybrid(ctx, queryEmbedding, query, docType, uc.fetchLimit(topK), uc.searchOptions(scope, docType, filter), domain.HybridSearchOptions{
+		VectorWeight: uc.retrievalCfg.VectorWeight,
+		LexicalWeight: uc.retrievalCfg.LexicalWeight,
+		K: uc.retrievalCfg.RRFK,
+		Candidates: max(uc.retrievalCfg.Candidates, topK),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hybrid search documents: %w", err)
+	}
+
+	// fused scores are tiny (1/(k+rank)), scale to the best one so they weigh like similarities in mmr
+	maxScore := 0.0
+	for _, doc := range docs {
+		maxScore = max(maxScore, doc.Score)
+	}
+
+	return uc.diversify(docs, topK, func(doc *domain.VectorDocument) float64 {
+		if maxScore == 0 {
+			return 0
+		}
+		return doc.Score / maxScore
+	}), nil
+}
+
+// the configured filter always applies, the caller filter narrows it further
+func (uc *vectorUsecase) searchOptions(scope domain.SearchScope, docType domain.DocumentType, filter domain.MetadataFilter) domain.SearchOptions {
+	metadata := append(append(domain.MetadataFilter(nil), uc.metadataFilter...), filter...)
+	return domain.SearchOptions{
+		PositionID: scope.PositionID,
+		KBVersionID: scope.Versions[docType],
+		MinSimilarity: uc.retrievalCfg.MinSimilarity,
+		Metadata: metadata,
+	}
 }
 
-func (uc *vectorUsecase) GetDocumentCount(ctx context.Context, docType domain.DocumentType) (int64, error) {
-	return uc.repo.Count(ctx, docType)
-}
\ No newline at end of file
+// mmr needs a wider candidate pool than the final top k
+func (uc *vectorUsecase) fetchLimit(topK int) int {
+	if uc.retrievalCfg.MMR {
+		return max(uc.retrievalCfg.Candidates, topK)
+	}
+	return topK
+}
+
+// re-select top k with mmr so overlapping sentence chunks do not crowd out other context
+func (uc *vectorUsecase) diversify(docs []*domain.VectorDocument, topK int, relevance func(doc *domain.VectorDocument) float64) []*domain.VectorDocument {
+	if !uc.retrievalCfg.MMR || len(docs) <= 1 {
+		return docs[:min(topK, len(docs))]
+	}
+
+	scores := make([]float64, len(docs))
+	embeddings := make([][]float32, len(docs))
+	for i, doc := range docs {
+		scores[i] = relevance(doc)
+		embeddings[i] = doc.Embedding.Slice()
+	}
+
+	selected := make([]*domain.VectorDocument, 0, topK)
+	for _, i := range service.SelectMMR(scores, embeddings, topK, uc.retrievalCfg.MMRLambda) {
+		selected = append(selected, docs[i])
+	}
+
+	return selected
+}
+
+func (uc *vectorUsecase) GetDocumentCount(ctx context.Context, positionID uuid.UUID, docType domain.DocumentType) (int64, error) {
+	return uc.repo.Count(ctx, positionID, docType)
+}
+
+// active chunk count per document type of a position
+func (uc *vectorUsecase) GetDocumentCounts(ctx context.Context, positionID uuid.UUID) (map[domain.DocumentType]int64, error) {
+	return uc.repo.CountByPosition(ctx, positionID)
+}
+
+// the live column, the configured dimension and a probe embedding must all agree,
+// otherwise inserts or similarity search fail at query time
+func (uc *vectorUsecase) CheckDimension(ctx context.Context, dimension int) error {
+	column, err := uc.repo.EmbeddingDimension(ctx)
+	if err != nil {
+		return err
+	}
+	if column != dimension {
+		return fmt.Errorf("embedding column is vector(%d) but GEMINI_DIMENSION is %d, run the migration with -rebuild-embeddings", column, dimension)
+	}
+
+	probe, err := uc.embeddingService.GenerateEmbedding(ctx, "embedding dimension probe")
+	if err != nil {
+		return fmt.Errorf("failed to generate probe embedding: %w", err)
+	}
+	if len(probe) != dimension {
+		return fmt.Errorf("embedding model returned %d dimensions but GEMINI_DIMENSION is %d", len(probe), dimension)
+	}
+
+	return nil
+}
+
+// embed every chunk without an embedding in batches, returns the number of chunks updated
+func (uc *vectorUsecase) ReembedMissing(ctx context.Context, batchSize int) (int, error) {
+	updated := 0
+	for {
+		docs, err := uc.repo.FindWithoutEmbedding(ctx, batchSize)
+		if err != nil {
+			return updated, err
+		}
+		if len(docs) == 0 {
+			return updated, nil
+		}
+
+		contents := make([]string, len(docs))
+		for i, doc := range docs {
+			contents[i] = doc.Content
+		}
+
+		embeddings, err := uc.embeddingService.GenerateBatchEmbeddings(ctx, contents)
+		if err != nil {
+			return updated, fmt.Errorf("failed to generate batch embeddings: %w", err)
+		}
+
+		modelID := uc.embeddingService.ModelID()
+		for i, doc := range docs {
+			if err := uc.repo.UpdateEmbedding(ctx, doc.ID, embeddings[i], modelID); err != nil {
+				return updated, err
+			}
+			updated++
+		}
+	}
+}
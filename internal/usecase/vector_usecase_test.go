@@ -0,0 +1,154 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/config"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/internal/service"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
+)
+
+// in-memory stores, only the methods the tests reach are implemented
+
+type fakeJobRepo struct {
+	domain.EvaluationJobRepository
+	jobs map[uuid.UUID]domain.EvaluationJob
+}
+
+func (r *fakeJobRepo) Update(ctx context.Context, job *domain.EvaluationJob) error {
+	stored := *job
+	stored.KBVersions = make(domain.KBVersionPins, len(job.KBVersions))
+	for docType, id := range job.KBVersions {
+		stored.KBVersions[docType] = id
+	}
+	r.jobs[job.ID] = stored
+	return nil
+}
+
+type fakeKBVersionRepo struct {
+	domain.KBVersionRepository
+	versions map[uuid.UUID]*domain.KBVersion
+	jobs *fakeJobRepo
+}
+
+func (r *fakeKBVersionRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.KBVersion, error) {
+	version, ok := r.versions[id]
+	if !ok {
+		return nil, errors.ErrKBVersionNotFound
+	}
+	return version, nil
+}
+
+func (r *fakeKBVersionRepo) FindActive(ctx context.Context, positionID uuid.UUID) ([]*domain.KBVersion, error) {
+	var active []*domain.KBVersion
+	for _, version := range r.versions {
+		if version.PositionID == positionID && version.Status == domain.KBActive {
+			active = append(active, version)
+		}
+	}
+	return active, nil
+}
+
+// same rule as the kb_versions containment query: stored pins of queued or processing jobs
+func (r *fakeKBVersionRepo) IsPinned(ctx context.Context, version *domain.KBVersion) (bool, error) {
+	for _, job := range r.jobs.jobs {
+		if (job.Status == domain.StatusQueued || job.Status == domain.StatusProcessing) && job.KBVersions[version.DocType] == version.ID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeKBVersionRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	delete(r.versions, id)
+	return nil
+}
+
+type fakeVectorRepo struct {
+	domain.VectorRepository
+}
+
+func (r *fakeVectorRepo) DeleteByVersion(ctx context.Context, kbVersionID uuid.UUID) error {
+	return nil
+}
+
+type fakeDocumentRepo struct {
+	domain.DocumentRepository
+}
+
+func (r *fakeDocumentRepo) FindByID(ctx context.Context, id uuid.UUID) (*domain.Document, error) {
+	return &domain.Document{ID: id, FilePath: id.String() + ".pdf"}, nil
+}
+
+type fakePDFService struct{}
+
+func (fakePDFService) ExtractText(filePath string) (string, error) {
+	return "backend engineer with five years of go experience", nil
+}
+
+// runs hook on the first search and stops the evaluation there
+type searchHook struct {
+	VectorUsecase
+	hook func()
+}
+
+var errSearchStopped = fmt.Errorf("search stopped")
+
+func (s *searchHook) Search(ctx context.Context, scope domain.SearchScope, query string, docType domain.DocumentType, topK int, filter domain.MetadataFilter) ([]*domain.VectorDocument, error) {
+	s.hook()
+	return nil, errSearchStopped
+}
+
+func TestDeleteVersionPinnedByRunningJob(t *testing.T) {
+	ctx := context.Background()
+	positionID := uuid.New()
+	pinned := domain.NewKBVersion(positionID, domain.JobDescription, "jd-v1.pdf", nil, nil, domain.ChunkingOptions{})
+	pinned.Status = domain.KBActive
+
+	jobRepo := &fakeJobRepo{jobs: make(map[uuid.UUID]domain.EvaluationJob)}
+	kbVersionRepo := &fakeKBVersionRepo{versions: map[uuid.UUID]*domain.KBVersion{pinned.ID: pinned}, jobs: jobRepo}
+	vectorUsecase := NewVectorUsecase(&fakeVectorRepo{}, kbVersionRepo, nil, nil, nil, &config.RetrievalConfig{})
+
+	job := domain.NewEvaluationJob(positionID, "Backend Engineer", uuid.New(), uuid.New(), false, "en")
+	job.Status = domain.StatusProcessing
+	if err := jobRepo.Update(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	// while the job searches, a new version is swapped in and the pinned one is deleted
+	var deleteErr error
+	hook := &searchHook{VectorUsecase: vectorUsecase, hook: func() {
+		pinned.Status = domain.KBArchived
+		_, deleteErr = vectorUsecase.DeleteVersion(ctx, pinned.ID)
+	}}
+	uc := &evaluationUsecase{
+		jobRepo: jobRepo,
+		documentRepo: &fakeDocumentRepo{},
+		vectorUsecase: hook,
+		pdfService: fakePDFService{},
+		injectionDetector: service.NewInjectionDetector(),
+	}
+
+	if err := uc.processEvaluation(ctx, job, nil); err == nil {
+		t.Fatal("processEvaluation() should stop at the first search")
+	}
+	if deleteErr != errors.ErrKBVersionInUse {
+		t.Fatalf("DeleteVersion() during the job error = %v, want %v", deleteErr, errors.ErrKBVersionInUse)
+	}
+	if _, ok := kbVersionRepo.versions[pinned.ID]; !ok {
+		t.Fatal("pinned version was deleted")
+	}
+
+	// the finished job releases its pins
+	job.Status = domain.StatusFailed
+	if err := jobRepo.Update(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vectorUsecase.DeleteVersion(ctx, pinned.ID); err != nil {
+		t.Fatalf("DeleteVersion() after the job error = %v", err)
+	}
+}
@@ -21,6 +21,20 @@ var (
 	ErrJobTimeout = errors.New("evaluation job timeout")
 
 	ErrQueueFull = errors.New("job queue is full")	
+
+	// position error
+	ErrPositionNotFound = errors.New("position not found")
+	ErrPositionExists = errors.New("position already exists")
+	ErrKnowledgeBaseIncomplete = errors.New("position has no active job description, case study or rubrics")
+
+	// knowledge base error
+	ErrKBVersionNotFound = errors.New("knowledge base version not found")
+	ErrKBVersionNotReady = errors.New("knowledge base version cannot be activated")
+	ErrKBVersionInUse = errors.New("knowledge base version is active, still ingesting or pinned by a running evaluation")
+	ErrKBVersionClaimed = errors.New("knowledge base version is already being ingested")
+
+	// llm error
+	ErrLLMUnavailable = errors.New("all llm models are unavailable")
 )
 
 type AppError struct {
@@ -0,0 +1,100 @@
+package rankeval
+
+import "math"
+
+// judged ranking of one query, relevant[i] is the judgement of the result at rank i+1.
+// relevantTotal is the number of relevant items in the collection, the ideal ranking puts them on top.
+// labels is the number of phrases or ids the query expects, labelsFound how many of them the results contain
+type Ranking struct {
+	Relevant []bool
+	RelevantTotal int
+	Labels int
+	LabelsFound int
+}
+
+// share of the relevant items in the results, 1 when nothing is relevant
+func Recall(r Ranking) float64 {
+	if r.RelevantTotal == 0 {
+		return 1
+	}
+
+	retrieved := 0
+	for _, relevant := range r.Relevant {
+		if relevant {
+			retrieved++
+		}
+	}
+	return float64(min(retrieved, r.RelevantTotal)) / float64(r.RelevantTotal)
+}
+
+// share of the expected labels found in the results, 1 when nothing is expected
+func Coverage(r Ranking) float64 {
+	if r.Labels == 0 {
+		return 1
+	}
+	return float64(min(r.LabelsFound, r.Labels)) / float64(r.Labels)
+}
+
+// 1 / rank of the first relevant result, 0 when none is relevant
+func ReciprocalRank(r Ranking) float64 {
+	for i, relevant := range r.Relevant {
+		if relevant {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// normalized discounted cumulative gain with binary gains over the first k results,
+// the ideal ranking puts min(k, relevantTotal) relevant results on top
+func NDCG(r Ranking, k int) float64 {
+	dcg := 0.0
+	for i, relevant := range r.Relevant[:min(k, len(r.Relevant))] {
+		if relevant {
+			dcg += 1 / math.Log2(float64(i+2))
+		}
+	}
+
+	idcg := 0.0
+	for i := 0; i < min(k, r.RelevantTotal); i++ {
+		idcg += 1 / math.Log2(float64(i+2))
+	}
+
+	if idcg == 0 {
+		return 0
+	}
+	return dcg / idcg
+}
+
+// mean recall@k, label coverage, mrr and ndcg@k over a set of queries
+type Summary struct {
+	Queries int
+	Skipped int // rankings without any relevant item, a mislabelled query would inflate recall
+	Recall float64
+	Coverage float64
+	MRR float64
+	NDCG float64
+}
+
+func (s *Summary) Add(r Ranking, k int) {
+	if r.RelevantTotal == 0 {
+		s.Skipped++
+		return
+	}
+
+	s.Queries++
+	s.Recall += Recall(r)
+	s.Coverage += Coverage(r)
+	s.MRR += ReciprocalRank(r)
+	s.NDCG += NDCG(r, k)
+}
+
+// averages of the added rankings
+func (s Summary) Mean() Summary {
+	if s.Queries == 0 {
+		return s
+	}
+
+	n := float64(s.Queries)
+	return Summary{Queries: s.Queries, Skipped: s.Skipped, Recall: s.Recall / n, Coverage: s.Coverage / n, MRR: s.MRR / n, NDCG: s.NDCG / n}
+}
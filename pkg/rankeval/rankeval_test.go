@@ -0,0 +1,119 @@
+package rankeval
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNDCG(t *testing.T) {
+	tests := []struct {
+		name string
+		ranking Ranking
+		k int
+		want float64
+	}{
+		{"perfect ranking", Ranking{Relevant: []bool{true, true, false}, RelevantTotal: 2}, 3, 1},
+		{"one phrase matching every result", Ranking{Relevant: []bool{true, true, true}, RelevantTotal: 3, Labels: 1, LabelsFound: 1}, 3, 1},
+		{"relevant at rank two", Ranking{Relevant: []bool{false, true, false}, RelevantTotal: 1}, 3, 1 / math.Log2(3)},
+		{"more relevant than k", Ranking{Relevant: []bool{true, true}, RelevantTotal: 5}, 2, 1},
+		{"relevant missing from results", Ranking{Relevant: []bool{true, false}, RelevantTotal: 2}, 2, 1 / (1 + 1/math.Log2(3))},
+		{"relevant below k", Ranking{Relevant: []bool{false, false, true}, RelevantTotal: 1}, 2, 0},
+		{"none relevant", Ranking{Relevant: []bool{false, false}, RelevantTotal: 2}, 2, 0},
+		{"nothing relevant in collection", Ranking{Relevant: []bool{false}}, 1, 0},
+		{"no results", Ranking{RelevantTotal: 2}, 5, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NDCG(tt.ranking, tt.k); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("NDCG() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecall(t *testing.T) {
+	tests := []struct {
+		name string
+		ranking Ranking
+		want float64
+	}{
+		{"all retrieved", Ranking{Relevant: []bool{true, false, true}, RelevantTotal: 2}, 1},
+		{"half retrieved", Ranking{Relevant: []bool{true, false}, RelevantTotal: 2}, 0.5},
+		{"none retrieved", Ranking{Relevant: []bool{false}, RelevantTotal: 3}, 0},
+		{"nothing relevant", Ranking{Relevant: []bool{false}}, 1},
+		{"more retrieved than total", Ranking{Relevant: []bool{true, true}, RelevantTotal: 1}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Recall(tt.ranking); got != tt.want {
+				t.Errorf("Recall() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	tests := []struct {
+		name string
+		ranking Ranking
+		want float64
+	}{
+		{"all labels found", Ranking{Labels: 2, LabelsFound: 2}, 1},
+		{"one of four", Ranking{Labels: 4, LabelsFound: 1}, 0.25},
+		{"no labels", Ranking{}, 1},
+		{"independent of relevant chunks", Ranking{Relevant: []bool{true, true, true}, RelevantTotal: 3, Labels: 2, LabelsFound: 1}, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Coverage(tt.ranking); got != tt.want {
+				t.Errorf("Coverage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReciprocalRank(t *testing.T) {
+	tests := []struct {
+		name string
+		relevant []bool
+		want float64
+	}{
+		{"first", []bool{true, false}, 1},
+		{"third", []bool{false, false, true}, 1.0 / 3},
+		{"none", []bool{false, false}, 0},
+		{"no results", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReciprocalRank(Ranking{Relevant: tt.relevant}); got != tt.want {
+				t.Errorf("ReciprocalRank() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryMean(t *testing.T) {
+	var s Summary
+	s.Add(Ranking{Relevant: []bool{true}, RelevantTotal: 1, Labels: 1, LabelsFound: 1}, 1)
+	s.Add(Ranking{Relevant: []bool{false}, RelevantTotal: 1, Labels: 2}, 1)
+
+	got := s.Mean()
+	want := Summary{Queries: 2, Recall: 0.5, Coverage: 0.5, MRR: 0.5, NDCG: 0.5}
+	if got != want {
+		t.Errorf("Mean() = %+v, want %+v", got, want)
+	}
+
+	// a query whose labels match nothing is skipped instead of scoring recall 1
+	s.Add(Ranking{Relevant: []bool{false}, Labels: 1}, 1)
+	if got := s.Mean(); got.Queries != 2 || got.Skipped != 1 || got.Recall != 0.5 {
+		t.Errorf("Mean() with a skipped ranking = %+v, want 2 queries, 1 skipped, recall 0.5", got)
+	}
+
+	if empty := (Summary{}).Mean(); empty != (Summary{}) {
+		t.Errorf("Mean() of empty summary = %+v, want zero", empty)
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/internal/usecase"
+	"github.com/sawalreverr/cv-reviewer/pkg/rankeval"
+)
+
+// one config of the eval set ready to search
+type evalRun struct {
+	config EvalConfig
+	vectorUsecase usecase.VectorUsecase
+	filter domain.MetadataFilter
+	chunks map[domain.DocumentType][]*domain.VectorDocument // every chunk of the searched version, the relevant ones are the ideal ranking
+	overall rankeval.Summary
+	byType map[domain.DocumentType]*rankeval.Summary
+}
+
+// retrieval quality of a labelled query set, two configs are reported side by side
+func runEval(args []string) error {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	setPath := fs.String("set", "./docs/evalset.yaml", "yaml or json set of labelled queries")
+	k := fs.Int("k", 0, "results judged per query, overrides k of the eval set")
+	verbose := fs.Bool("v", false, "print the judged results of every query")
+	fs.Parse(args)
+
+	set, err := LoadEvalSet(*setPath)
+	if err != nil {
+		return err
+	}
+	if *k > 0 {
+		set.K = *k
+	}
+
+	a, err := newApp(false)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if err := a.checkDimension(ctx); err != nil {
+		return err
+	}
+	position, err := a.positionBySlug(ctx, set.Position)
+	if err != nil {
+		return err
+	}
+
+	active, err := a.vectorUsecase.ActiveVersions(ctx, position.ID)
+	if err != nil {
+		return err
+	}
+
+	runs := make([]*evalRun, len(set.Configs))
+	for i, cfg := range set.Configs {
+		retrieval := cfg.retrieval(a.cfg.Retrieval)
+		filter, _ := domain.ParseMetadataFilter(cfg.MetadataFilter)
+		runs[i] = &evalRun{config: cfg, vectorUsecase: a.newVectorUsecase(&retrieval), filter: filter, chunks: make(map[domain.DocumentType][]*domain.VectorDocument), byType: make(map[domain.DocumentType]*rankeval.Summary)}
+	}
+
+	for _, query := range set.Queries {
+		for _, run := range runs {
+			scope := domain.SearchScope{PositionID: position.ID, Versions: run.config.Versions}
+			docs, err := run.vectorUsecase.Search(ctx, scope, query.Query, query.Type, set.K, run.filter)
+			if err != nil {
+				return fmt.Errorf("config %s, query %q: %w", run.config.Name, query.Query, err)
+			}
+
+			corpus, err := run.versionChunks(ctx, active, query.Type)
+			if err != nil {
+				return fmt.Errorf("config %s: %w", run.config.Name, err)
+			}
+
+			ranking := judge(query, docs, corpus)
+			if ranking.RelevantTotal == 0 {
+				log.Printf("warning: config %s, %s query %q matches no chunk of the searched version, check its phrases and chunk_ids. excluded from the means", run.config.Name, query.Type, query.Query)
+			}
+			run.overall.Add(ranking, set.K)
+			if run.byType[query.Type] == nil {
+				run.byType[query.Type] = &rankeval.Summary{}
+			}
+			run.byType[query.Type].Add(ranking, set.K)
+
+			if *verbose {
+				printJudged(run.config.Name, query, docs, ranking)
+			}
+		}
+	}
+
+	printReport(set, runs)
+	return nil
+}
+
+// chunks of the version a config searches for a document type, the pinned one or the active one
+func (run *evalRun) versionChunks(ctx context.Context, active domain.KBVersionPins, docType domain.DocumentType) ([]*domain.VectorDocument, error) {
+	if chunks, ok := run.chunks[docType]; ok {
+		return chunks, nil
+	}
+
+	versionID, ok := run.config.Versions[docType]
+	if !ok {
+		versionID, ok = active[docType]
+	}
+
+	var chunks []*domain.VectorDocument
+	for ok {
+		page, _, err := run.vectorUsecase.ListVersionChunks(ctx, versionID, 500, len(chunks))
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		chunks = append(chunks, page...)
+	}
+	run.chunks[docType] = chunks
+
+	return chunks, nil
+}
+
+// relevant results in rank order, the relevant chunks of the searched version and how many of the expected phrases and chunk ids showed up
+func judge(query EvalQuery, docs, corpus []*domain.VectorDocument) rankeval.Ranking {
+	ranking := rankeval.Ranking{Relevant: make([]bool, len(docs)), Labels: len(query.Phrases) + len(query.ChunkIDs)}
+
+	found := make(map[string]bool)
+	for i, doc := range docs {
+		labels := matchLabels(query, doc)
+		ranking.Relevant[i] = len(labels) > 0
+		for _, label := range labels {
+			found[label] = true
+		}
+	}
+	ranking.LabelsFound = len(found)
+
+	for _, doc := range corpus {
+		if len(matchLabels(query, doc)) > 0 {
+			ranking.RelevantTotal++
+		}
+	}
+
+	return ranking
+}
+
+// phrases and chunk ids of the query the chunk matches
+func matchLabels(query EvalQuery, doc *domain.VectorDocument) []string {
+	var labels []string
+	for _, id := range query.ChunkIDs {
+		if doc.ID == id {
+			labels = append(labels, "id:"+id.String())
+		}
+	}
+
+	content := normalize(doc.Content)
+	for _, phrase := range query.Phrases {
+		if strings.Contains(content, normalize(phrase)) {
+			labels = append(labels, "phrase:"+phrase)
+		}
+	}
+
+	return labels
+}
+
+// lowercase with collapsed whitespace, pdf text breaks lines in the middle of phrases
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+func printJudged(configName string, query EvalQuery, docs []*domain.VectorDocument, ranking rankeval.Ranking) {
+	fmt.Printf("[%s] %s %q: recall %.2f (%d relevant chunks), coverage %.2f, rr %.2f\n", configName, query.Type, query.Query, rankeval.Recall(ranking), ranking.RelevantTotal, rankeval.Coverage(ranking), rankeval.ReciprocalRank(ranking))
+	for i, doc := range docs {
+		mark := " "
+		if ranking.Relevant[i] {
+			mark = "+"
+		}
+		fmt.Printf("  %s %d. %.3f %s  %s\n", mark, i+1, doc.Similarity, doc.ID, preview(doc.Content, 80))
+	}
+}
+
+func preview(text string, n int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	if len(runes) <= n {
+		return string(runes)
+	}
+	return string(runes[:n]) + "..."
+}
+
+// one row per scope and metric, a delta column when two configs are compared
+func printReport(set *EvalSet, runs []*evalRun) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+
+	header := "scope\tqueries\tmetric\t"
+	for _, run := range runs {
+		header += run.config.Name + "\t"
+	}
+	if len(runs) == 2 {
+		header += "delta\t"
+	}
+	fmt.Fprintln(w, header)
+
+	scopes := []string{"all"}
+	for _, docType := range domain.KnowledgeBaseTypes {
+		if runs[0].byType[docType] != nil {
+			scopes = append(scopes, string(docType))
+		}
+	}
+
+	for _, scope := range scopes {
+		means := make([]rankeval.Summary, len(runs))
+		for i, run := range runs {
+			if scope == "all" {
+				means[i] = run.overall.Mean()
+			} else {
+				means[i] = run.byType[domain.DocumentType(scope)].Mean()
+			}
+		}
+
+		metrics := []struct {
+			name string
+			value func(rankeval.Summary) float64
+		}{
+			{fmt.Sprintf("recall@%d", set.K), func(s rankeval.Summary) float64 { return s.Recall }},
+			{"coverage", func(s rankeval.Summary) float64 { return s.Coverage }},
+			{"mrr", func(s rankeval.Summary) float64 { return s.MRR }},
+			{fmt.Sprintf("ndcg@%d", set.K), func(s rankeval.Summary) float64 { return s.NDCG }},
+		}
+		for _, metric := range metrics {
+			row := fmt.Sprintf("%s\t%d\t%s\t", scope, means[0].Queries, metric.name)
+			for _, mean := range means {
+				row += fmt.Sprintf("%.3f\t", metric.value(mean))
+			}
+			if len(means) == 2 {
+				row += fmt.Sprintf("%+.3f\t", metric.value(means[1])-metric.value(means[0]))
+			}
+			fmt.Fprintln(w, row)
+		}
+	}
+
+	w.Flush()
+	log.Printf("evaluated %d queries against %d configs", len(set.Queries), len(runs))
+	for _, run := range runs {
+		if run.overall.Skipped > 0 {
+			log.Printf("config %s: %d queries without relevant chunks were skipped", run.config.Name, run.overall.Skipped)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/sawalreverr/cv-reviewer/config"
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"github.com/sawalreverr/cv-reviewer/internal/usecase"
+)
+
+// labelled retrieval queries of one position, run against one or two configs
+type EvalSet struct {
+	Position string `yaml:"position" json:"position"`
+	K int `yaml:"k" json:"k"`
+	Configs []EvalConfig `yaml:"configs" json:"configs"`
+	Queries []EvalQuery `yaml:"queries" json:"queries"`
+}
+
+// a retrieval setup to measure, unset fields keep the RETRIEVAL_* config and the active versions
+type EvalConfig struct {
+	Name string `yaml:"name" json:"name"`
+	Versions domain.KBVersionPins `yaml:"versions" json:"versions"` // e.g. a re-chunked staging version before it is activated
+	Mode string `yaml:"mode" json:"mode"` // vector or hybrid
+	MinSimilarity *float64 `yaml:"min_similarity" json:"min_similarity"`
+	MMR *bool `yaml:"mmr" json:"mmr"`
+	MMRLambda *float64 `yaml:"mmr_lambda" json:"mmr_lambda"`
+	Candidates *int `yaml:"candidates" json:"candidates"`
+	MetadataFilter string `yaml:"metadata_filter" json:"metadata_filter"`
+}
+
+// a result is relevant when its chunk id is listed or its content contains one of the phrases,
+// every phrase and chunk id counts as one expected label for coverage
+type EvalQuery struct {
+	Query string `yaml:"query" json:"query"`
+	Type domain.DocumentType `yaml:"type" json:"type"`
+	Phrases []string `yaml:"phrases" json:"phrases"`
+	ChunkIDs []uuid.UUID `yaml:"chunk_ids" json:"chunk_ids"`
+}
+
+func LoadEvalSet(path string) (*EvalSet, error) {
+	var set EvalSet
+	if err := decodeFile(path, &set); err != nil {
+		return nil, fmt.Errorf("failed to load eval set: %w", err)
+	}
+
+	if set.K <= 0 {
+		set.K = 5
+	}
+	if len(set.Configs) == 0 {
+		set.Configs = []EvalConfig{{Name: "current"}}
+	}
+
+	if err := set.validate(); err != nil {
+		return nil, err
+	}
+
+	return &set, nil
+}
+
+func (s *EvalSet) validate() error {
+	if s.Position == "" {
+		return fmt.Errorf("eval set has no position")
+	}
+	if len(s.Configs) > 2 {
+		return fmt.Errorf("eval set compares at most two configs, got %d", len(s.Configs))
+	}
+	if len(s.Queries) == 0 {
+		return fmt.Errorf("eval set has no queries")
+	}
+
+	for i, cfg := range s.Configs {
+		if cfg.Name == "" {
+			s.Configs[i].Name = fmt.Sprintf("config_%d", i+1)
+		}
+		if cfg.Mode != "" && cfg.Mode != usecase.RetrievalVector && cfg.Mode != usecase.RetrievalHybrid {
+			return fmt.Errorf("config %s: mode must be vector or hybrid", s.Configs[i].Name)
+		}
+		if _, err := domain.ParseMetadataFilter(cfg.MetadataFilter); err != nil {
+			return fmt.Errorf("config %s: %w", s.Configs[i].Name, err)
+		}
+	}
+
+	for i, query := range s.Queries {
+		if query.Query == "" {
+			return fmt.Errorf("query %d is empty", i+1)
+		}
+		if !domain.IsKnowledgeBaseType(query.Type) {
+			return fmt.Errorf("query %q has invalid type %q", query.Query, query.Type)
+		}
+		if len(query.Phrases) == 0 && len(query.ChunkIDs) == 0 {
+			return fmt.Errorf("query %q has no phrases or chunk_ids", query.Query)
+		}
+	}
+
+	return nil
+}
+
+// the configured retrieval settings with the overrides of this config
+func (c EvalConfig) retrieval(base config.RetrievalConfig) config.RetrievalConfig {
+	retrieval := base
+	if c.Mode != "" {
+		retrieval.Mode = c.Mode
+	}
+	if c.MinSimilarity != nil {
+		retrieval.MinSimilarity = *c.MinSimilarity
+	}
+	if c.MMR != nil {
+		retrieval.MMR = *c.MMR
+	}
+	if c.MMRLambda != nil {
+		retrieval.MMRLambda = *c.MMRLambda
+	}
+	if c.Candidates != nil {
+		retrieval.Candidates = *c.Candidates
+	}
+	return retrieval
+}
@@ -2,115 +2,442 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"sync"
 
+	"github.com/google/uuid"
 	"github.com/sawalreverr/cv-reviewer/config"
 	"github.com/sawalreverr/cv-reviewer/internal/domain"
 	"github.com/sawalreverr/cv-reviewer/internal/repository"
 	"github.com/sawalreverr/cv-reviewer/internal/service"
 	"github.com/sawalreverr/cv-reviewer/internal/usecase"
+	"github.com/sawalreverr/cv-reviewer/pkg/errors"
 )
 
+const usage = `usage: go run ./scripts/ingestion <command> [flags]
+
+commands:
+  ingest    -manifest docs/manifest.yaml [-dry-run] [-concurrency 2]
+  list      [-position slug]
+  delete    -version id | -position slug -type doc_type [-dry-run]
+  activate  -version id
+  reembed   -position slug [-type doc_type] | -missing [-batch 100] [-dry-run]
+  eval      -set docs/evalset.yaml [-k 5] [-v]
+`
+
+// knowledge base tooling shared by every command
+type app struct {
+	cfg *config.Config
+	vectorUsecase usecase.VectorUsecase
+	positionUsecase usecase.PositionUsecase
+	// kept to build usecases with other retrieval settings
+	vectorRepo domain.VectorRepository
+	kbVersionRepo domain.KBVersionRepository
+	embeddingService service.EmbeddingService
+}
+
 func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+
+	var err error
+	switch command {
+	case "ingest":
+		err = runIngest(args)
+	case "list":
+		err = runList(args)
+	case "delete":
+		err = runDelete(args)
+	case "activate":
+		err = runActivate(args)
+	case "reembed":
+		err = runReembed(args)
+	case "eval":
+		err = runEval(args)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Printf("%s failed: %v", command, err)
+		os.Exit(1)
+	}
+}
+
+// migrations are skipped for dry runs so nothing is written
+func newApp(migrate bool) (*app, error) {
 	// load config
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if _, err := domain.ParseMetadataFilter(cfg.Retrieval.MetadataFilter); err != nil {
+		return nil, fmt.Errorf("invalid RETRIEVAL_METADATA_FILTER: %w", err)
 	}
 
 	// connect db
 	db, err := config.NewDatabase(&cfg.Database)
 	if err != nil {
-		log.Fatalf("failed to connect to database: %v", err)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
 	// run migrations
-	if err := config.RunMigration(db); err != nil {
-		log.Fatalf("failed to run migrations: %v", err)
+	if migrate {
+		if err := config.RunMigration(db, int(*cfg.Gemini.Dimension)); err != nil {
+			return nil, fmt.Errorf("failed to run migrations: %w", err)
+		}
 	}
 
 	// init services
-	pdfService := service.NewPDFService()
-	chunkingService := service.NewChunkingService()
-	embeddingService, err := service.NewEmbeddingService(&cfg.Gemini)
+	rateLimiter := service.NewRateLimiter(&cfg.RateLimit, repository.NewRateLimitRepository(db))
+	embeddingService, err := service.NewEmbeddingService(&cfg.Gemini, rateLimiter)
 	if err != nil {
-		log.Fatalf("failed to create embedding service: %v", err)
+		return nil, fmt.Errorf("failed to create embedding service: %w", err)
 	}
 
 	// init repo
 	vectorRepo := repository.NewVectorRepository(db)
-	vectorUsecase := usecase.NewVectorUsecase(vectorRepo, pdfService, chunkingService, embeddingService)
+	kbVersionRepo := repository.NewKBVersionRepository(db)
+	positionUsecase := usecase.NewPositionUsecase(repository.NewPositionRepository(db), vectorRepo)
+
+	a := &app{cfg: cfg, positionUsecase: positionUsecase, vectorRepo: vectorRepo, kbVersionRepo: kbVersionRepo, embeddingService: embeddingService}
+	a.vectorUsecase = a.newVectorUsecase(&cfg.Retrieval)
+	return a, nil
+}
+
+func (a *app) newVectorUsecase(retrievalCfg *config.RetrievalConfig) usecase.VectorUsecase {
+	return usecase.NewVectorUsecase(a.vectorRepo, a.kbVersionRepo, service.NewPDFService(), service.NewChunkingService(), a.embeddingService, retrievalCfg)
+}
+
+func (a *app) checkDimension(ctx context.Context) error {
+	if err := a.vectorUsecase.CheckDimension(ctx, int(*a.cfg.Gemini.Dimension)); err != nil {
+		return fmt.Errorf("embedding dimension check failed: %w", err)
+	}
+	return nil
+}
+
+// position by slug, created from the manifest entry when missing (unless dry run)
+func (a *app) resolvePosition(ctx context.Context, entry ManifestPosition, dryRun bool) (*domain.Position, error) {
+	position, err := a.positionUsecase.GetPositionBySlug(ctx, entry.Slug)
+	if err != errors.ErrPositionNotFound {
+		return position, err
+	}
+
+	title := entry.Title
+	if title == "" {
+		title = entry.Slug
+	}
+	if dryRun {
+		log.Printf("[dry-run] would create position %s (%s)", entry.Slug, title)
+		return domain.NewPosition(entry.Slug, title, entry.Description), nil
+	}
+
+	return a.positionUsecase.CreatePosition(ctx, entry.Slug, title, entry.Description)
+}
+
+func (a *app) positionBySlug(ctx context.Context, slug string) (*domain.Position, error) {
+	position, err := a.positionUsecase.GetPositionBySlug(ctx, slug)
+	if err != nil {
+		return nil, fmt.Errorf("position %s: %w", slug, err)
+	}
+	return position, nil
+}
+
+type ingestTask struct {
+	position *domain.Position
+	doc ManifestDocument
+}
+
+func runIngest(args []string) error {
+	fs := flag.NewFlagSet("ingest", flag.ExitOnError)
+	manifestPath := fs.String("manifest", "./docs/manifest.yaml", "yaml or json manifest of knowledge base documents")
+	dryRun := fs.Bool("dry-run", false, "validate the manifest and chunk the documents without embedding or writing")
+	concurrency := fs.Int("concurrency", 2, "documents ingested in parallel")
+	fs.Parse(args)
+
+	manifest, err := LoadManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+
+	a, err := newApp(!*dryRun)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !*dryRun {
+		if err := a.checkDimension(ctx); err != nil {
+			return err
+		}
+	}
+
+	var tasks []ingestTask
+	for _, entry := range manifest.Positions {
+		position, err := a.resolvePosition(ctx, entry, *dryRun)
+		if err != nil {
+			return fmt.Errorf("failed to resolve position %s: %w", entry.Slug, err)
+		}
+		for _, doc := range entry.Documents {
+			tasks = append(tasks, ingestTask{position, doc})
+		}
+	}
+
+	// bounded worker pool, every document gets its own version so failures are independent
+	failed := 0
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, max(*concurrency, 1))
+	for _, task := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(task ingestTask) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := a.ingest(ctx, task, *dryRun); err != nil {
+				log.Printf("failed to ingest %s (%s/%s): %v", task.doc.Path, task.position.Slug, task.doc.Type, err)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}(task)
+	}
+	wg.Wait()
+
+	log.Printf("ingestion completed: %d/%d documents successful", len(tasks)-failed, len(tasks))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d documents failed", failed, len(tasks))
+	}
+	return nil
+}
+
+func (a *app) ingest(ctx context.Context, task ingestTask, dryRun bool) error {
+	// check if file exist
+	if _, err := os.Stat(task.doc.Path); err != nil {
+		return err
+	}
+
+	if dryRun {
+		chunks, err := a.vectorUsecase.ChunkDocument(task.doc.Path, task.doc.Chunking.options())
+		if err != nil {
+			return err
+		}
+		log.Printf("[dry-run] %s -> %s/%s: %d chunks", task.doc.Path, task.position.Slug, task.doc.Type, len(chunks))
+		return nil
+	}
+
+	// ingest into a new version, the current one stays live until the swap
+	version, err := a.vectorUsecase.IngestDocument(ctx, task.position.ID, task.doc.Path, task.doc.Type, task.doc.Metadata, task.doc.Chunking.options())
+	if err != nil {
+		return err
+	}
+
+	log.Printf("ingested %s -> %s/%s version %d: %d chunks (%d added, %d unchanged, %d reembedded, %d removed)", task.doc.Path, task.position.Slug, task.doc.Type, version.Version, version.ChunkCount, version.Report.Added, version.Report.Unchanged, version.Report.Reembedded, version.Report.Removed)
+	return nil
+}
+
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	slug := fs.String("position", "", "only this position")
+	fs.Parse(args)
+
+	a, err := newApp(false)
+	if err != nil {
+		return err
+	}
 
 	ctx := context.Background()
-	systemDocs := []struct{
-		Path string
-		Doctype domain.DocumentType
-		Metadata map[string]interface{}
-	} {
-		{
-			Path: "./docs/job_description.pdf",
-			Doctype: domain.JobDescription,
-			Metadata: map[string]interface{}{
-				"source": "job_description",
-				"description": "Backend Product Engineer Job Description",
-				"version": "1.0",
-			},
-		},
-		{
-			Path: "./docs/case_study_brief.pdf",
-			Doctype: domain.CaseStudyBrief,
-			Metadata: map[string]interface{}{
-				"source": "case_study",
-				"description": "Case Study Brief for Backend Developer Assesment",
-				"version": "1.0",
-			},
-		},
-		{
-			Path: "./docs/cv_scoring_rubric.pdf",
-			Doctype: domain.CVRubric,
-			Metadata: map[string]interface{}{
-				"source": "cv_rubric",
-				"description": "CV Evaluation Scoring Rubric",
-				"version": "1.0",
-			},
-		},
-		{
-			Path: "./docs/project_scoring_rubric.pdf",
-			Doctype: domain.ProjectRubric,
-			Metadata: map[string]interface{}{
-				"source": "project_rubric",
-				"description": "Project Deliverable Evaluation Scoring Rubric",
-				"version": "1.0",
-			},
-		},
-	}
-
-	successCount := 0
-	for _, doc := range systemDocs{
-		log.Printf("ingesting: %s\ntype: %s", doc.Path, doc.Doctype)
-
-		// check if file exist
-		if _, err := os.Stat(doc.Path); os.IsNotExist(err) {
-			log.Printf(" file not found: %s", doc.Path)
+	positions, err := a.positionUsecase.ListPositions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, position := range positions {
+		if *slug != "" && position.Slug != *slug {
 			continue
 		}
 
-		// delete existing documents
-		log.Printf("cleaning existing document by type: %s", doc.Doctype)
-		if err := vectorUsecase.DeleteDocumentsByType(ctx, doc.Doctype); err != nil {
-			log.Printf("failed to delete existing document: %v", err)
+		fmt.Printf("%s  %s  (%s)\n", position.Slug, position.Title, position.ID)
+		versions, err := a.vectorUsecase.ListVersions(ctx, position.ID)
+		if err != nil {
+			return err
+		}
+		for _, version := range versions {
+			fmt.Printf("  %-17s v%-3d %-8s %4d chunks  %s  %s\n", version.DocType, version.Version, version.Status, version.ChunkCount, version.ID, version.Source)
+		}
+	}
+
+	return nil
+}
+
+func runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ExitOnError)
+	versionStr := fs.String("version", "", "archived or failed version id to delete")
+	slug := fs.String("position", "", "position slug, with -type deletes every version of that type")
+	docType := fs.String("type", "", "document type")
+	dryRun := fs.Bool("dry-run", false, "print what would be deleted")
+	fs.Parse(args)
+
+	a, err := newApp(false)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch {
+	case *versionStr != "":
+		versionID, err := uuid.Parse(*versionStr)
+		if err != nil {
+			return fmt.Errorf("invalid version id: %w", err)
+		}
+		if *dryRun {
+			version, err := a.vectorUsecase.GetVersion(ctx, versionID)
+			if err != nil {
+				return err
+			}
+			log.Printf("[dry-run] would delete %s version %d (%s, %d chunks)", version.DocType, version.Version, version.Status, version.ChunkCount)
+			return nil
+		}
+
+		version, err := a.vectorUsecase.DeleteVersion(ctx, versionID)
+		if err != nil {
+			return err
+		}
+		log.Printf("deleted %s version %d", version.DocType, version.Version)
+
+	case *slug != "" && *docType != "":
+		if !domain.IsKnowledgeBaseType(domain.DocumentType(*docType)) {
+			return fmt.Errorf("invalid type %q", *docType)
+		}
+		position, err := a.positionBySlug(ctx, *slug)
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			count, err := a.vectorUsecase.GetDocumentCount(ctx, position.ID, domain.DocumentType(*docType))
+			if err != nil {
+				return err
+			}
+			log.Printf("[dry-run] would delete every %s version of %s (%d active chunks)", *docType, position.Slug, count)
+			return nil
+		}
+
+		deleted, err := a.vectorUsecase.DeleteDocumentsByType(ctx, position.ID, domain.DocumentType(*docType))
+		if err != nil {
+			return err
+		}
+		log.Printf("deleted %d %s versions of %s", deleted, *docType, position.Slug)
+
+	default:
+		return fmt.Errorf("delete needs -version or -position with -type")
+	}
+
+	return nil
+}
+
+// rollback, the previous active version is archived
+func runActivate(args []string) error {
+	fs := flag.NewFlagSet("activate", flag.ExitOnError)
+	versionStr := fs.String("version", "", "knowledge base version id to make active")
+	fs.Parse(args)
+
+	versionID, err := uuid.Parse(*versionStr)
+	if err != nil {
+		return fmt.Errorf("invalid version id: %w", err)
+	}
+
+	a, err := newApp(false)
+	if err != nil {
+		return err
+	}
+
+	version, err := a.vectorUsecase.ActivateVersion(context.Background(), versionID)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("activated %s version %d (%d chunks)", version.DocType, version.Version, version.ChunkCount)
+	return nil
+}
+
+func runReembed(args []string) error {
+	fs := flag.NewFlagSet("reembed", flag.ExitOnError)
+	slug := fs.String("position", "", "re-embed the active versions of this position")
+	docType := fs.String("type", "", "only this document type")
+	missing := fs.Bool("missing", false, "embed chunks left without an embedding after -rebuild-embeddings")
+	batch := fs.Int("batch", 100, "chunks per embedding batch")
+	dryRun := fs.Bool("dry-run", false, "print what would be re-embedded")
+	fs.Parse(args)
+
+	if *slug == "" && !*missing {
+		return fmt.Errorf("reembed needs -position or -missing")
+	}
+
+	a, err := newApp(false)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if !*dryRun {
+		if err := a.checkDimension(ctx); err != nil {
+			return err
+		}
+	}
+
+	if *missing {
+		if *dryRun {
+			log.Printf("[dry-run] would embed every chunk without an embedding")
+			return nil
 		}
+		updated, err := a.vectorUsecase.ReembedMissing(ctx, *batch)
+		if err != nil {
+			return err
+		}
+		log.Printf("embedded %d chunks", updated)
+		return nil
+	}
 
-		// ingest document
-		if err := vectorUsecase.IngestDocument(ctx, doc.Path, doc.Doctype, doc.Metadata); err != nil {
-			log.Printf("failed to ingest %s: %v", doc.Path, err)
+	position, err := a.positionBySlug(ctx, *slug)
+	if err != nil {
+		return err
+	}
+	active, err := a.vectorUsecase.ActiveVersions(ctx, position.ID)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, activeType := range domain.KnowledgeBaseTypes {
+		versionID, ok := active[activeType]
+		if !ok || (*docType != "" && string(activeType) != *docType) {
+			continue
+		}
+		if *dryRun {
+			log.Printf("[dry-run] would re-embed %s/%s version %s", position.Slug, activeType, versionID)
+			continue
 		}
 
-		count, _ := vectorUsecase.GetDocumentCount(ctx, doc.Doctype)
-		log.Printf("successfully ingested: %s (%d chunks stored)\n", doc.Path, count)
-		successCount++
+		version, err := a.vectorUsecase.ReembedVersion(ctx, versionID, *batch)
+		if err != nil {
+			log.Printf("failed to re-embed %s/%s: %v", position.Slug, activeType, err)
+			failed++
+			continue
+		}
+		log.Printf("re-embedded %s/%s into version %d (%s): %d chunks", position.Slug, activeType, version.Version, version.ID, version.ChunkCount)
 	}
 
-	log.Printf("\ningestion completed: %d/%d documents succesful", successCount, len(systemDocs))
-}	
\ No newline at end of file
+	if failed > 0 {
+		return fmt.Errorf("%d document types failed", failed)
+	}
+	return nil
+}
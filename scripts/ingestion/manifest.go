@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sawalreverr/cv-reviewer/internal/domain"
+	"go.yaml.in/yaml/v3"
+)
+
+// knowledge base documents to ingest, grouped by position
+type Manifest struct {
+	Positions []ManifestPosition `yaml:"positions" json:"positions"`
+}
+
+type ManifestPosition struct {
+	Slug string `yaml:"slug" json:"slug"`
+	Title string `yaml:"title" json:"title"` // used when the position is created
+	Description string `yaml:"description" json:"description"`
+	Documents []ManifestDocument `yaml:"documents" json:"documents"`
+}
+
+type ManifestDocument struct {
+	Path string `yaml:"path" json:"path"` // relative to the manifest file
+	Type domain.DocumentType `yaml:"type" json:"type"`
+	Metadata map[string]interface{} `yaml:"metadata" json:"metadata"`
+	Chunking ManifestChunking `yaml:"chunking" json:"chunking"`
+}
+
+type ManifestChunking struct {
+	Strategy domain.ChunkStrategy `yaml:"strategy" json:"strategy"` // sentence (default) or fixed
+	Size int `yaml:"size" json:"size"`
+	Overlap int `yaml:"overlap" json:"overlap"`
+}
+
+func (c ManifestChunking) options() domain.ChunkingOptions {
+	return domain.ChunkingOptions{Strategy: c.Strategy, Size: c.Size, Overlap: c.Overlap}
+}
+
+// json for .json files, yaml otherwise
+func decodeFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return json.Unmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+// document paths are resolved against the manifest directory
+func LoadManifest(path string) (*Manifest, error) {
+	var manifest Manifest
+	if err := decodeFile(path, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to load manifest: %w", err)
+	}
+
+	base := filepath.Dir(path)
+	for i := range manifest.Positions {
+		for j := range manifest.Positions[i].Documents {
+			doc := &manifest.Positions[i].Documents[j]
+			if doc.Path != "" && !filepath.IsAbs(doc.Path) {
+				doc.Path = filepath.Join(base, doc.Path)
+			}
+		}
+	}
+
+	if err := manifest.validate(); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// one document per position and type, each ingest of the same pair would race for the next version
+func (m *Manifest) validate() error {
+	if len(m.Positions) == 0 {
+		return fmt.Errorf("manifest has no positions")
+	}
+
+	slugs := make(map[string]bool)
+	for _, position := range m.Positions {
+		if position.Slug == "" {
+			return fmt.Errorf("manifest position without slug")
+		}
+		if slugs[position.Slug] {
+			return fmt.Errorf("position %s is listed more than once, merge its documents into one entry", position.Slug)
+		}
+		slugs[position.Slug] = true
+
+		seen := make(map[domain.DocumentType]bool)
+		for _, doc := range position.Documents {
+			if doc.Path == "" {
+				return fmt.Errorf("position %s: document without path", position.Slug)
+			}
+			if !domain.IsKnowledgeBaseType(doc.Type) {
+				return fmt.Errorf("position %s: %s has invalid type %q", position.Slug, doc.Path, doc.Type)
+			}
+			if seen[doc.Type] {
+				return fmt.Errorf("position %s: more than one %s document", position.Slug, doc.Type)
+			}
+			seen[doc.Type] = true
+
+			switch doc.Chunking.Strategy {
+			case "", domain.ChunkSentence, domain.ChunkFixed:
+			default:
+				return fmt.Errorf("position %s: %s has unknown chunking strategy %q", position.Slug, doc.Path, doc.Chunking.Strategy)
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/sawalreverr/cv-reviewer/config"
+	"github.com/sawalreverr/cv-reviewer/internal/repository"
+	"github.com/sawalreverr/cv-reviewer/internal/service"
+	"github.com/sawalreverr/cv-reviewer/internal/usecase"
 )
 
 func main() {
 	// flag
 	drop := flag.Bool("drop", false, "drop all tables before migrations")
+	rebuild := flag.Bool("rebuild-embeddings", false, "recreate the embedding column with GEMINI_DIMENSION and re-embed every chunk")
 	flag.Parse()
 
 	log.Println("starting database migrations...")
@@ -44,7 +49,52 @@ func main() {
 	fmt.Println()
 
 	// run migrations
-	if err := config.RunMigration(db); err != nil {
+	dimension := int(*cfg.Gemini.Dimension)
+	if err := config.RunMigration(db, dimension); err != nil {
 		log.Fatalf("migration failed: %v", err)
 	}
-}
\ No newline at end of file
+
+	ctx := context.Background()
+	vectorRepo := repository.NewVectorRepository(db)
+	column, err := vectorRepo.EmbeddingDimension(ctx)
+	if err != nil {
+		log.Fatalf("failed to read embedding column: %v", err)
+	}
+
+	if !*rebuild {
+		if column != dimension {
+			log.Printf("embedding column is vector(%d) but GEMINI_DIMENSION is %d, run with -rebuild-embeddings", column, dimension)
+		}
+		return
+	}
+
+	// rebuild column, re-embed stored chunks, then index the new column
+	if column != dimension {
+		if err := config.RebuildEmbeddingColumn(db, dimension); err != nil {
+			log.Fatalf("%v", err)
+		}
+	} else {
+		log.Printf("embedding column already vector(%d), re-embedding missing chunks only", dimension)
+	}
+
+	rateLimiter := service.NewRateLimiter(&cfg.RateLimit, repository.NewRateLimitRepository(db))
+	embeddingService, err := service.NewEmbeddingService(&cfg.Gemini, rateLimiter)
+	if err != nil {
+		log.Fatalf("failed to create embedding service: %v", err)
+	}
+	vectorUsecase := usecase.NewVectorUsecase(vectorRepo, repository.NewKBVersionRepository(db), service.NewPDFService(), service.NewChunkingService(), embeddingService, &cfg.Retrieval)
+
+	if err := vectorUsecase.CheckDimension(ctx, dimension); err != nil {
+		log.Fatalf("embedding dimension check failed: %v", err)
+	}
+
+	updated, err := vectorUsecase.ReembedMissing(ctx, 50)
+	if err != nil {
+		log.Fatalf("re-embedding failed after %d chunks: %v", updated, err)
+	}
+	log.Printf("re-embedded %d chunks", updated)
+
+	if err := config.CreateVectorIndex(db); err != nil {
+		log.Fatalf("failed to create vector index: %v", err)
+	}
+}